@@ -0,0 +1,250 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// default max chunk size in MB when config.settings has no chunksize
+const (
+	defaultChunkSizeMB       = 64
+	defaultChunkSizeMBSince6 = 128
+)
+
+// balance models, the balancer equalises chunk counts until MongoDB 6.0 and
+// data size since
+const (
+	balanceModelChunks = "chunks"
+	balanceModelBytes  = "bytes"
+	balanceModelAuto   = "auto"
+)
+
+// BalancerSettings is mongo config.settings balancer document
+type BalancerSettings struct {
+	Stopped           bool          `bson:"stopped"`
+	Mode              string        `bson:"mode"`
+	ActiveWindow      *ActiveWindow `bson:"activeWindow"`
+	SecondaryThrottle interface{}   `bson:"_secondaryThrottle"`
+}
+
+// ActiveWindow is the time of day the balancer may run, in the time zone of
+// the config servers
+type ActiveWindow struct {
+	Start string `bson:"start" json:"start"`
+	Stop  string `bson:"stop" json:"stop"`
+}
+
+// ChunkSizeSettings is mongo config.settings chunksize document
+type ChunkSizeSettings struct {
+	Value int `bson:"value"`
+}
+
+// BalancerLock is mongo config.locks balancer document
+type BalancerLock struct {
+	State int       `bson:"state"`
+	Who   string    `bson:"who"`
+	When  time.Time `bson:"when"`
+	Why   string    `bson:"why"`
+}
+
+// BalancerStatus is the balancerStatus command output of MongoDB 3.4+
+type BalancerStatus struct {
+	Mode            string `bson:"mode"`
+	InBalancerRound bool   `bson:"inBalancerRound"`
+}
+
+// ClusterStatus is the cluster-wide balancer state, InActiveWindow is checked
+// at WindowCheckedAt in Timezone on the clock of WindowClock
+type ClusterStatus struct {
+	Version           string        `json:"version"`
+	BalancerEnabled   bool          `json:"balancerEnabled"`
+	BalancerRunning   *bool         `json:"balancerRunning"`
+	ActiveWindow      *ActiveWindow `json:"activeWindow"`
+	InActiveWindow    bool          `json:"inActiveWindow"`
+	WindowCheckedAt   time.Time     `json:"windowCheckedAt"`
+	Timezone          string        `json:"timezone"`
+	WindowClock       string        `json:"windowClock"`
+	SecondaryThrottle string        `json:"secondaryThrottle"`
+	ChunkSize         int64         `json:"chunkSize"`
+	BalanceModel      string        `json:"balanceModel"`
+}
+
+// ServerStatus is the part of mongo serverStatus output used for the clock
+type ServerStatus struct {
+	LocalTime time.Time `bson:"localTime"`
+}
+
+// getClusterStatus checks the active window in loc, the time zone of the
+// config servers, the local one when nil
+func getClusterStatus(session *mgo.Session, loc *time.Location) (ClusterStatus, error) {
+	configDb := session.DB("config")
+	var status ClusterStatus
+
+	buildInfo, err := session.BuildInfo()
+	if err != nil {
+		return status, fmt.Errorf("cannot run buildInfo: %v", err)
+	}
+	status.Version = buildInfo.Version
+
+	var balancer BalancerSettings
+	err = configDb.C("settings").FindId("balancer").One(&balancer)
+	if err != nil && err != mgo.ErrNotFound {
+		return status, fmt.Errorf("cannot read config.settings: %v", err)
+	}
+	status.BalancerEnabled = !balancer.Stopped && balancer.Mode != "off"
+	status.ActiveWindow = balancer.ActiveWindow
+	if loc == nil {
+		loc = time.Local
+	}
+	now, clock := serverTime(session)
+	status.WindowCheckedAt = now.In(loc)
+	status.Timezone = timezoneName(loc)
+	status.WindowClock = clock
+	status.InActiveWindow = inActiveWindow(balancer.ActiveWindow, status.WindowCheckedAt)
+	status.SecondaryThrottle = formatSecondaryThrottle(balancer.SecondaryThrottle)
+
+	chunkSizeMB := defaultChunkSizeMB
+	status.BalanceModel = balanceModelChunks
+	if buildInfo.VersionAtLeast(6, 0) {
+		chunkSizeMB = defaultChunkSizeMBSince6
+		status.BalanceModel = balanceModelBytes
+	}
+	var chunkSize ChunkSizeSettings
+	err = configDb.C("settings").FindId("chunksize").One(&chunkSize)
+	if err != nil && err != mgo.ErrNotFound {
+		return status, fmt.Errorf("cannot read config.settings: %v", err)
+	}
+	if chunkSize.Value > 0 {
+		chunkSizeMB = chunkSize.Value
+	}
+	status.ChunkSize = int64(chunkSizeMB) * 1024 * 1024
+
+	// balancerStatus needs clusterMonitor, fall back to the balancer lock
+	// which is only meaningful before MongoDB 3.4, the config server primary
+	// holds it all the time since then and running stays unknown
+	var balancerStatus BalancerStatus
+	err = session.Run(bson.M{"balancerStatus": 1}, &balancerStatus)
+	if err == nil {
+		status.BalancerRunning = &balancerStatus.InBalancerRound
+		if balancerStatus.Mode != "" {
+			status.BalancerEnabled = balancerStatus.Mode != "off"
+		}
+		return status, nil
+	}
+	if buildInfo.VersionAtLeast(3, 4) {
+		return status, nil
+	}
+	var lock BalancerLock
+	err = configDb.C("locks").FindId("balancer").One(&lock)
+	if err != nil && err != mgo.ErrNotFound {
+		return status, fmt.Errorf("cannot read config.locks: %v", err)
+	}
+	running := lock.State > 0
+	status.BalancerRunning = &running
+	return status, nil
+}
+
+// serverTime reads the clock of mongos, falling back to the client clock when
+// serverStatus is not permitted
+func serverTime(session *mgo.Session) (time.Time, string) {
+	var serverStatus ServerStatus
+	err := session.Run(bson.M{"serverStatus": 1}, &serverStatus)
+	if err != nil || serverStatus.LocalTime.IsZero() {
+		return time.Now(), "client"
+	}
+	return serverStatus.LocalTime, "server"
+}
+
+// timezoneName names the local zone after the client it comes from
+func timezoneName(loc *time.Location) string {
+	if loc == time.Local {
+		return "client local"
+	}
+	return loc.String()
+}
+
+// inActiveWindow also handles windows spanning midnight, no window means
+// the balancer may always run. now is expected in the config servers' zone.
+func inActiveWindow(window *ActiveWindow, now time.Time) bool {
+	if window == nil {
+		return true
+	}
+	start, err := minuteOfDay(window.Start)
+	if err != nil {
+		return false
+	}
+	stop, err := minuteOfDay(window.Stop)
+	if err != nil {
+		return false
+	}
+	current := now.Hour()*60 + now.Minute()
+	if start <= stop {
+		return start <= current && current < stop
+	}
+	return current >= start || current < stop
+}
+
+// minuteOfDay parses the "HH:MM" of an active window
+func minuteOfDay(clock string) (int, error) {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func formatSecondaryThrottle(throttle interface{}) string {
+	switch v := throttle.(type) {
+	case nil:
+		return "default"
+	case bool:
+		return strconv.FormatBool(v)
+	case bson.M:
+		return fmt.Sprintf("writeConcern %v", map[string]interface{}(v))
+	}
+	return fmt.Sprint(throttle)
+}
+
+func renderClusterStatus(w io.Writer, markdown bool, status ClusterStatus) {
+	window := "always"
+	if status.ActiveWindow != nil {
+		inside := "outside"
+		if status.InActiveWindow {
+			inside = "inside"
+		}
+		window = fmt.Sprintf("%s-%s (%s at %s %s, %s clock)",
+			status.ActiveWindow.Start, status.ActiveWindow.Stop, inside,
+			status.WindowCheckedAt.Format("15:04"), status.Timezone, status.WindowClock)
+	}
+
+	running := "unknown"
+	if status.BalancerRunning != nil {
+		running = strconv.FormatBool(*status.BalancerRunning)
+	}
+
+	table := newTable(w, markdown)
+	table.SetHeader([]string{"cluster", "value"})
+	table.AppendBulk([][]string{
+		{"version", status.Version},
+		{"balancer", enabledOrStopped(status.BalancerEnabled)},
+		{"balancerRunning", running},
+		{"activeWindow", window},
+		{"secondaryThrottle", status.SecondaryThrottle},
+		{"chunksize(MB)", strconv.FormatInt(status.ChunkSize/1024/1024, 10)},
+		{"balanceModel", status.BalanceModel},
+	})
+	table.Render()
+	fmt.Fprintln(w)
+}
+
+func enabledOrStopped(enabled bool) string {
+	if enabled {
+		return "enabled"
+	}
+	return "stopped"
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInActiveWindow(t *testing.T) {
+	at := func(clock string) time.Time {
+		parsed, _ := time.Parse("15:04", clock)
+		return parsed
+	}
+	tests := []struct {
+		window *ActiveWindow
+		now    string
+		want   bool
+	}{
+		{nil, "12:00", true},
+		{&ActiveWindow{Start: "01:00", Stop: "05:00"}, "00:59", false},
+		{&ActiveWindow{Start: "01:00", Stop: "05:00"}, "01:00", true},
+		{&ActiveWindow{Start: "01:00", Stop: "05:00"}, "04:59", true},
+		{&ActiveWindow{Start: "01:00", Stop: "05:00"}, "05:00", false},
+		// windows spanning midnight
+		{&ActiveWindow{Start: "23:00", Stop: "06:00"}, "23:30", true},
+		{&ActiveWindow{Start: "23:00", Stop: "06:00"}, "00:00", true},
+		{&ActiveWindow{Start: "23:00", Stop: "06:00"}, "05:59", true},
+		{&ActiveWindow{Start: "23:00", Stop: "06:00"}, "06:00", false},
+		{&ActiveWindow{Start: "23:00", Stop: "06:00"}, "12:00", false},
+		{&ActiveWindow{Start: "22:00", Stop: "xx"}, "23:00", false},
+	}
+	for _, tt := range tests {
+		if got := inActiveWindow(tt.window, at(tt.now)); got != tt.want {
+			t.Errorf("inActiveWindow(%+v, %s) = %v, want %v", tt.window, tt.now, got, tt.want)
+		}
+	}
+}
+
+func TestInActiveWindowTimezone(t *testing.T) {
+	tokyo := time.FixedZone("JST", 9*60*60)
+	window := &ActiveWindow{Start: "01:00", Stop: "05:00"}
+	// 18:00 UTC is 03:00 the next day on config servers in Tokyo
+	now := time.Date(2024, 1, 1, 18, 0, 0, 0, time.UTC)
+	if inActiveWindow(window, now) {
+		t.Error("18:00 UTC is inside 01:00-05:00 UTC")
+	}
+	if !inActiveWindow(window, now.In(tokyo)) {
+		t.Error("03:00 JST is outside 01:00-05:00 JST")
+	}
+}
+
+func TestRenderClusterStatusRunning(t *testing.T) {
+	running := true
+	tests := []struct {
+		running *bool
+		want    string
+	}{
+		{nil, "unknown"},
+		{&running, "true"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		renderClusterStatus(&buf, true, ClusterStatus{BalancerRunning: tt.running})
+		if !strings.Contains(buf.String(), "| balancerRunning   | "+tt.want) {
+			t.Errorf("balancerRunning %v rendered\n%s", tt.running, buf.String())
+		}
+	}
+}
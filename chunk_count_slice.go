@@ -0,0 +1,18 @@
+// Generated by: gen
+// TypeWriter: slice
+// Directive: +gen on ChunkCount
+
+package main
+
+// ChunkCountSlice is a slice of type ChunkCount. Use it where you would use []ChunkCount.
+type ChunkCountSlice []ChunkCount
+
+// Where returns a new ChunkCountSlice whose elements return true for func. See: http://clipperhouse.github.io/gen/#Where
+func (rcv ChunkCountSlice) Where(fn func(ChunkCount) bool) (result ChunkCountSlice) {
+	for _, v := range rcv {
+		if fn(v) {
+			result = append(result, v)
+		}
+	}
+	return result
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"strings"
+
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// ChunkCount is the number of chunks of a collection on a shard
+// +gen slice:"Where"
+type ChunkCount struct {
+	Ns    string
+	Shard string
+	Jumbo bool
+	Count int
+}
+
+// chunkGroup is a $group output document of getChunkCounts
+type chunkGroup struct {
+	ID struct {
+		Ns    string      `bson:"ns"`
+		UUID  bson.Binary `bson:"uuid"`
+		Shard string      `bson:"shard"`
+		Jumbo bool        `bson:"jumbo"`
+	} `bson:"_id"`
+	Count int `bson:"count"`
+}
+
+// error codes of servers without the aggregate command or one of its stages
+const (
+	codeCommandNotFound        = 59
+	codeUnrecognizedStageOld   = 16436
+	codeUnrecognizedStageSince = 40324
+)
+
+// getChunkCounts counts the chunks of collections per shard on the server,
+// falling back to reading every chunk only when aggregation is not available
+func getChunkCounts(db *mgo.Database, collections CollectionSlice, byUUID bool) (ChunkCountSlice, error) {
+	key := "ns"
+	namespaces := make(map[string]string)
+	values := make([]interface{}, len(collections))
+	for i, c := range collections {
+		if byUUID {
+			namespaces[string(c.UUID.Data)] = c.ID
+			values[i] = c.UUID
+		} else {
+			namespaces[c.ID] = c.ID
+			values[i] = c.ID
+		}
+	}
+	if byUUID {
+		key = "uuid"
+	}
+
+	pipeline := []bson.M{
+		{"$match": bson.M{key: bson.M{"$in": values}}},
+		{"$group": bson.M{
+			"_id":   bson.M{key: "$" + key, "shard": "$shard", "jumbo": "$jumbo"},
+			"count": bson.M{"$sum": 1},
+		}},
+	}
+	var groups []chunkGroup
+	err := db.C("chunks").Pipe(pipeline).All(&groups)
+	if err != nil && !aggregationUnavailable(err) {
+		return nil, err
+	}
+	if err != nil {
+		chunks, err := getChunks(db, collections, byUUID)
+		if err != nil {
+			return nil, err
+		}
+		return countChunks(chunks), nil
+	}
+
+	counts := make(ChunkCountSlice, 0, len(groups))
+	for _, g := range groups {
+		ns := g.ID.Ns
+		if byUUID {
+			ns = namespaces[string(g.ID.UUID.Data)]
+		}
+		counts = append(counts, ChunkCount{Ns: ns, Shard: g.ID.Shard, Jumbo: g.ID.Jumbo, Count: g.Count})
+	}
+	return counts, nil
+}
+
+// getShardChunkCounts counts the chunks of every collection per shard,
+// config.system.sessions included, which has no config.databases entry
+func getShardChunkCounts(db *mgo.Database) (map[string]int, error) {
+	pipeline := []bson.M{
+		{"$group": bson.M{"_id": "$shard", "count": bson.M{"$sum": 1}}},
+	}
+	var groups []struct {
+		Shard string `bson:"_id"`
+		Count int    `bson:"count"`
+	}
+	err := db.C("chunks").Pipe(pipeline).All(&groups)
+	if err != nil && !aggregationUnavailable(err) {
+		return nil, err
+	}
+	counts := make(map[string]int)
+	if err != nil {
+		var chunk Chunk
+		iter := db.C("chunks").Find(bson.M{}).Select(bson.M{"shard": 1}).Iter()
+		for iter.Next(&chunk) {
+			counts[chunk.Shard]++
+		}
+		return counts, iter.Close()
+	}
+	for _, g := range groups {
+		counts[g.Shard] = g.Count
+	}
+	return counts, nil
+}
+
+// aggregationUnavailable tells a server without aggregation apart from the
+// timeouts, authorization and network errors reading the chunks would hit too
+func aggregationUnavailable(err error) bool {
+	e, ok := err.(*mgo.QueryError)
+	if !ok {
+		return false
+	}
+	switch e.Code {
+	case codeCommandNotFound, codeUnrecognizedStageOld, codeUnrecognizedStageSince:
+		return true
+	}
+	return strings.HasPrefix(e.Message, "no such cmd")
+}
+
+// countChunks does in memory what the getChunkCounts pipeline does
+func countChunks(chunks ChunkSlice) ChunkCountSlice {
+	index := make(map[ChunkCount]int)
+	var counts ChunkCountSlice
+	for _, chunk := range chunks {
+		key := ChunkCount{Ns: chunk.Ns, Shard: chunk.Shard, Jumbo: chunk.Jumbo}
+		i, ok := index[key]
+		if !ok {
+			i = len(counts)
+			index[key] = i
+			counts = append(counts, key)
+		}
+		counts[i].Count++
+	}
+	return counts
+}
+
+// Sum returns the number of chunks in rcv
+func (rcv ChunkCountSlice) Sum() int {
+	sum := 0
+	for _, v := range rcv {
+		sum += v.Count
+	}
+	return sum
+}
+
+// chunksKeyedByUUID detects the MongoDB 5.0+ config.chunks schema where
+// chunks refer to their collection by uuid instead of ns
+func chunksKeyedByUUID(db *mgo.Database) (bool, error) {
+	var chunk Chunk
+	err := db.C("chunks").Find(bson.M{}).One(&chunk)
+	if err == mgo.ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return chunk.Ns == "" && len(chunk.UUID.Data) > 0, nil
+}
+
+// getChunks returns the chunks of collections with Ns set on both schemas
+func getChunks(db *mgo.Database, collections CollectionSlice, byUUID bool) (ChunkSlice, error) {
+	var chunks ChunkSlice
+	err := db.C("chunks").Find(bson.M{}).All(&chunks)
+	if err != nil {
+		return nil, err
+	}
+
+	namespaces := make(map[string]string)
+	for _, c := range collections {
+		if byUUID {
+			namespaces[string(c.UUID.Data)] = c.ID
+		} else {
+			namespaces[c.ID] = c.ID
+		}
+	}
+
+	var result ChunkSlice
+	for _, chunk := range chunks {
+		key := chunk.Ns
+		if byUUID {
+			key = string(chunk.UUID.Data)
+		}
+		if ns, ok := namespaces[key]; ok {
+			chunk.Ns = ns
+			result = append(result, chunk)
+		}
+	}
+	return result, nil
+}
+
+// getCollectionChunks returns the chunks of collection ordered by min
+func getCollectionChunks(db *mgo.Database, collection Collection, byUUID bool) (ChunkSlice, error) {
+	query := bson.M{"ns": collection.ID}
+	if byUUID {
+		query = bson.M{"uuid": collection.UUID}
+	}
+	var chunks ChunkSlice
+	err := db.C("chunks").Find(query).Sort("min").All(&chunks)
+	for i := range chunks {
+		chunks[i].Ns = collection.ID
+	}
+	return chunks, err
+}
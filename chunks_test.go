@@ -0,0 +1,30 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	mgo "gopkg.in/mgo.v2"
+)
+
+func TestAggregationUnavailable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&mgo.QueryError{Code: 59, Message: "no such command: 'aggregate'"}, true},
+		{&mgo.QueryError{Message: "no such cmd: aggregate"}, true},
+		{&mgo.QueryError{Code: 16436, Message: "Unrecognized pipeline stage name: '$group'"}, true},
+		{&mgo.QueryError{Code: 40324, Message: "Unrecognized pipeline stage name: '$group'"}, true},
+		{&mgo.QueryError{Code: 13, Message: "not authorized on config to execute command"}, false},
+		{&mgo.QueryError{Code: 50, Message: "operation exceeded time limit"}, false},
+		{io.EOF, false},
+		{errors.New("no reachable servers"), false},
+	}
+	for _, tt := range tests {
+		if got := aggregationUnavailable(tt.err); got != tt.want {
+			t.Errorf("aggregationUnavailable(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// DataSize is mongo dataSize command output
+type DataSize struct {
+	Size       int64 `bson:"size"`
+	NumObjects int64 `bson:"numObjects"`
+}
+
+// ChunkSizes is the distribution of the real chunk sizes of a collection
+type ChunkSizes struct {
+	Smallest  int64 `json:"smallest"`
+	P50       int64 `json:"p50"`
+	P90       int64 `json:"p90"`
+	P99       int64 `json:"p99"`
+	Largest   int64 `json:"largest"`
+	Oversized int   `json:"oversized"`
+}
+
+// chunkDataSize is the dataSize of a chunk on its shard
+type chunkDataSize struct {
+	Shard string
+	Size  int64
+}
+
+// getChunkDataSize runs dataSize on the key range of a chunk, an estimate
+// uses the average object size instead of reading every document
+func getChunkDataSize(session *mgo.Session, collection Collection, chunk Chunk, estimate bool) (DataSize, error) {
+	s := session.Copy()
+	defer s.Close()
+
+	dbName, _ := splitNamespace(collection.ID)
+	var dataSize DataSize
+	err := s.DB(dbName).Run(bson.D{
+		{Name: "dataSize", Value: collection.ID},
+		{Name: "keyPattern", Value: collection.Key},
+		{Name: "min", Value: chunk.Min},
+		{Name: "max", Value: chunk.Max},
+		{Name: "estimate", Value: estimate},
+	}, &dataSize)
+	return dataSize, err
+}
+
+// getExactChunkSizes reads the chunks of collection and runs dataSize on them
+func getExactChunkSizes(session *mgo.Session, collection Collection, byUUID bool, sem chan struct{}) ([]chunkDataSize, error) {
+	chunks, err := getCollectionChunks(session.DB("config"), collection, byUUID)
+	if err != nil {
+		return nil, err
+	}
+	return getChunkDataSizes(session, collection, chunks, sem)
+}
+
+// getChunkDataSizes runs dataSize on every chunk, sem bounds the commands in
+// flight across all collections
+func getChunkDataSizes(session *mgo.Session, collection Collection, chunks ChunkSlice, sem chan struct{}) ([]chunkDataSize, error) {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var firstErr error
+	sizes := make([]chunkDataSize, len(chunks))
+	for i := range chunks {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			dataSize, err := getChunkDataSize(session, collection, chunks[i], false)
+			if err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+				return
+			}
+			sizes[i] = chunkDataSize{Shard: chunks[i].Shard, Size: dataSize.Size}
+		}(i)
+	}
+	wg.Wait()
+	return sizes, firstErr
+}
+
+// summarizeChunkSizes returns the distribution and the bytes per shard
+func summarizeChunkSizes(sizes []chunkDataSize, maxChunkSize int64) (ChunkSizes, map[string]int64) {
+	var result ChunkSizes
+	shardSizes := make(map[string]int64)
+	if len(sizes) == 0 {
+		return result, shardSizes
+	}
+
+	sorted := make([]int64, len(sizes))
+	for i, s := range sizes {
+		sorted[i] = s.Size
+		shardSizes[s.Shard] += s.Size
+		if maxChunkSize > 0 && s.Size > maxChunkSize {
+			result.Oversized++
+		}
+	}
+	sort.Slice(sorted, func(i int, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+	result.Smallest = sorted[0]
+	result.P50 = percentile(sorted, 50)
+	result.P90 = percentile(sorted, 90)
+	result.P99 = percentile(sorted, 99)
+	result.Largest = sorted[len(sorted)-1]
+	return result, shardSizes
+}
+
+// percentile is the nearest-rank percentile of sorted
+func percentile(sorted []int64, p int) int64 {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// renderChunkSizes renders the distribution of the measured chunk sizes and
+// the data they sum to on every shard
+func renderChunkSizes(w io.Writer, markdown bool, report Report) {
+	header := []string{
+		"CollectionName",
+		"chunks",
+		"smallest(KB)",
+		"p50(KB)",
+		"p90(KB)",
+		"p99(KB)",
+		"largest(KB)",
+		"oversizedChunks",
+	}
+	for _, shard := range report.Shards {
+		header = append(header, shard+" size(MB)")
+	}
+	table := newTable(w, markdown)
+	table.SetHeader(header)
+	for _, c := range report.Collections {
+		if c.ChunkSizes == nil {
+			continue
+		}
+		row := []string{
+			c.Name,
+			strconv.Itoa(c.Chunks),
+			formatKB(c.ChunkSizes.Smallest),
+			formatKB(c.ChunkSizes.P50),
+			formatKB(c.ChunkSizes.P90),
+			formatKB(c.ChunkSizes.P99),
+			formatKB(c.ChunkSizes.Largest),
+			strconv.Itoa(c.ChunkSizes.Oversized),
+		}
+		for _, s := range c.Shards {
+			row = append(row, formatMB(s.DataSize))
+		}
+		table.Append(row)
+	}
+	table.Render()
+}
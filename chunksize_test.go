@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSummarizeChunkSizes(t *testing.T) {
+	sizes := []chunkDataSize{{"s1", 100}, {"s2", 300}, {"s1", 200}, {"s2", 400}}
+	distribution, shardSizes := summarizeChunkSizes(sizes, 250)
+	want := ChunkSizes{Smallest: 100, P50: 200, P90: 400, P99: 400, Largest: 400, Oversized: 2}
+	if distribution != want {
+		t.Errorf("distribution = %+v, want %+v", distribution, want)
+	}
+	if shardSizes["s1"] != 300 || shardSizes["s2"] != 700 {
+		t.Errorf("shard sizes = %v, want s1 300 and s2 700", shardSizes)
+	}
+}
+
+func TestRenderChunkSizesShardTotals(t *testing.T) {
+	report := Report{
+		Shards: []string{"s1", "s2"},
+		Collections: []CollectionStatus{{
+			Name:       "app.events",
+			Chunks:     3,
+			ChunkSizes: &ChunkSizes{Smallest: 1024, P50: 2048, P90: 2048, P99: 2048, Largest: 2048},
+			Shards: []ShardChunks{
+				{Shard: "s1", Chunks: 2, DataSize: 3 * 1024 * 1024},
+				{Shard: "s2", Chunks: 1, DataSize: 1024 * 1024},
+			},
+		}},
+	}
+	var buf bytes.Buffer
+	renderChunkSizes(&buf, true, report)
+	lines := strings.Split(buf.String(), "\n")
+	for _, want := range []string{"S1 SIZE(MB)", "S2 SIZE(MB)"} {
+		if !strings.Contains(strings.ToUpper(lines[0]), want) {
+			t.Errorf("header %q lacks %s", lines[0], want)
+		}
+	}
+	cells := strings.Split(strings.Trim(lines[2], " |"), "|")
+	s1, s2 := strings.TrimSpace(cells[len(cells)-2]), strings.TrimSpace(cells[len(cells)-1])
+	if s1 != "3.00" || s2 != "1.00" {
+		t.Errorf("shard totals = %s, %s, want 3.00, 1.00", s1, s2)
+	}
+}
@@ -0,0 +1,457 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/codegangsta/cli"
+	mgo "gopkg.in/mgo.v2"
+)
+
+// statusFlags are the flags of the status command
+func statusFlags() []cli.Flag {
+	return []cli.Flag{
+		cli.BoolFlag{
+			Name:  "per-shard, s",
+			Usage: "show chunks, documents and data size of each collection on each shard",
+		},
+		cli.BoolFlag{
+			Name:  "exact-chunk-sizes",
+			Usage: "run dataSize on every chunk to report the real chunk size distribution, this reads all the data",
+		},
+		cli.IntFlag{
+			Name:  "concurrency",
+			Value: 4,
+			Usage: "number of dataSize commands run at the same time with --exact-chunk-sizes",
+		},
+		cli.StringFlag{
+			Name:  "balance-model",
+			Value: balanceModelAuto,
+			Usage: "balance chunk counts (chunks), data size (bytes) or what the server does (auto, bytes since MongoDB 6.0)",
+		},
+		cli.BoolFlag{
+			Name:  "metadata",
+			Usage: "add the shard key, unique, dropped, lastmod epoch and default collation columns",
+		},
+		cli.BoolFlag{
+			Name:  "storage",
+			Usage: "add the on-disk size, index size and compression ratio columns",
+		},
+		cli.BoolFlag{
+			Name:  "thresholds",
+			Usage: "add the difference between the most and the least loaded shard, the migration threshold and whether the balancer acts",
+		},
+		cli.BoolFlag{
+			Name:  "imbalance",
+			Usage: "add the fewest and most chunks on a shard, their standard deviation and coefficient of variation and the percent of data to move",
+		},
+		cli.StringFlag{
+			Name:  "sort",
+			Value: "name",
+			Usage: "sort the collections of each database by name or imbalance",
+		},
+		cli.BoolFlag{
+			Name:  "include-dropped",
+			Usage: "show the collections config.collections keeps as dropped",
+		},
+		cli.BoolFlag{
+			Name:  "watch, w",
+			Usage: "refresh the report every --interval and show the progress since the previous sample",
+		},
+		cli.DurationFlag{
+			Name:  "interval, n",
+			Value: 10 * time.Second,
+			Usage: "refresh interval of --watch",
+		},
+	}
+}
+
+// flagBool is true when the flag is set on the command or globally
+func flagBool(c *cli.Context, name string) bool {
+	return c.Bool(name) || c.GlobalBool(name)
+}
+
+// flagInt prefers the command flag over the global one
+func flagInt(c *cli.Context, name string) int {
+	if c.IsSet(name) || !c.GlobalIsSet(name) {
+		return c.Int(name)
+	}
+	return c.GlobalInt(name)
+}
+
+// flagString prefers the command flag over the global one
+func flagString(c *cli.Context, name string) string {
+	if c.IsSet(name) || !c.GlobalIsSet(name) {
+		return c.String(name)
+	}
+	return c.GlobalString(name)
+}
+
+// flagDuration prefers the command flag over the global one
+func flagDuration(c *cli.Context, name string) time.Duration {
+	if c.IsSet(name) || !c.GlobalIsSet(name) {
+		return c.Duration(name)
+	}
+	return c.GlobalDuration(name)
+}
+
+func statusCommand(global *GlobalOptions) cli.Command {
+	return cli.Command{
+		Name:   "status",
+		Usage:  "show the chunk status of each collection, the default command",
+		Flags:  statusFlags(),
+		Action: statusAction(global),
+	}
+}
+
+func statusAction(global *GlobalOptions) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		format, err := global.outputFormat()
+		if err != nil {
+			return err
+		}
+		loc, err := global.location()
+		if err != nil {
+			return err
+		}
+
+		// init mongodb client
+		session, err := global.connect()
+		if err != nil {
+			return err
+		}
+		defer session.Close()
+
+		opts := StatusOptions{
+			DatabasePatterns: global.DatabasePatterns,
+			AllDatabases:     global.AllDatabases,
+			PerShard:         flagBool(c, "per-shard"),
+			ExactChunkSizes:  flagBool(c, "exact-chunk-sizes"),
+			BalanceModel:     flagString(c, "balance-model"),
+			Metadata:         flagBool(c, "metadata"),
+			Storage:          flagBool(c, "storage"),
+			Thresholds:       flagBool(c, "thresholds"),
+			Imbalance:        flagBool(c, "imbalance"),
+			IncludeDropped:   flagBool(c, "include-dropped"),
+			Concurrency:      flagInt(c, "concurrency"),
+			Timezone:         loc,
+			Format:           format,
+		}
+		switch flagString(c, "sort") {
+		case "name":
+		case "imbalance":
+			opts.SortByImbalance = true
+		default:
+			return cli.NewExitError("unknown sort order: "+flagString(c, "sort"), exitFatal)
+		}
+		switch opts.BalanceModel {
+		case balanceModelChunks, balanceModelBytes, balanceModelAuto:
+		default:
+			return cli.NewExitError("unknown balance model: "+opts.BalanceModel, exitFatal)
+		}
+		if flagBool(c, "watch") {
+			interval := flagDuration(c, "interval")
+			if interval <= 0 {
+				return cli.NewExitError("--interval must be positive", exitFatal)
+			}
+			if err := watchStatus(session, opts, interval); err != nil {
+				return cli.NewExitError(err.Error(), exitFatal)
+			}
+			return nil
+		}
+
+		return renderReport(session, opts)
+	}
+}
+
+// renderReport renders a single report to stdout
+func renderReport(session *mgo.Session, opts StatusOptions) error {
+	report, err := getReport(session, opts)
+	if err != nil {
+		return cli.NewExitError(err.Error(), exitFatal)
+	}
+	if err := render(os.Stdout, opts.Format, report); err != nil {
+		return cli.NewExitError(err.Error(), exitFatal)
+	}
+	if reportFailures(report) > 0 {
+		return cli.NewExitError("", exitPartial)
+	}
+	return nil
+}
+
+func chunksCommand(global *GlobalOptions) cli.Command {
+	return cli.Command{
+		Name:  "chunks",
+		Usage: "show the chunks and data size of each collection on each shard",
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "exact-chunk-sizes",
+				Usage: "run dataSize on every chunk instead of estimating from the average chunk size",
+			},
+			cli.IntFlag{
+				Name:  "concurrency",
+				Value: 4,
+				Usage: "number of dataSize commands run at the same time with --exact-chunk-sizes",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			format, err := global.outputFormat()
+			if err != nil {
+				return err
+			}
+			loc, err := global.location()
+			if err != nil {
+				return err
+			}
+			session, err := global.connect()
+			if err != nil {
+				return err
+			}
+			defer session.Close()
+
+			return renderReport(session, StatusOptions{
+				DatabasePatterns: global.DatabasePatterns,
+				AllDatabases:     global.AllDatabases,
+				PerShard:         true,
+				Timezone:         loc,
+				ExactChunkSizes:  flagBool(c, "exact-chunk-sizes"),
+				Concurrency:      flagInt(c, "concurrency"),
+				Format:           format,
+			})
+		},
+	}
+}
+
+func balancerCommand(global *GlobalOptions) cli.Command {
+	return cli.Command{
+		Name:  "balancer",
+		Usage: "show the cluster balancer state, active window and chunk size",
+		Action: func(c *cli.Context) error {
+			format, err := global.outputFormat()
+			if err != nil {
+				return err
+			}
+			loc, err := global.location()
+			if err != nil {
+				return err
+			}
+			session, err := global.connect()
+			if err != nil {
+				return err
+			}
+			defer session.Close()
+
+			cluster, err := getClusterStatus(session, loc)
+			if err != nil {
+				return cli.NewExitError(err.Error(), exitFatal)
+			}
+			if err := renderBalancer(os.Stdout, format, cluster); err != nil {
+				return cli.NewExitError(err.Error(), exitFatal)
+			}
+			return nil
+		},
+	}
+}
+
+func renderBalancer(w io.Writer, format string, cluster ClusterStatus) error {
+	if format == formatTable || format == formatMarkdown {
+		renderClusterStatus(w, format == formatMarkdown, cluster)
+		return nil
+	}
+	window := ""
+	if cluster.ActiveWindow != nil {
+		window = cluster.ActiveWindow.Start + "-" + cluster.ActiveWindow.Stop
+	}
+	return renderView(w, format, view{
+		header: []string{"version", "balancerEnabled", "balancerRunning", "activeWindow", "inActiveWindow", "windowCheckedAt", "timezone", "windowClock", "secondaryThrottle", "chunksize(B)", "balanceModel"},
+		rows: [][]string{{
+			cluster.Version,
+			formatBool(cluster.BalancerEnabled),
+			formatOptionalBool(cluster.BalancerRunning),
+			window,
+			formatBool(cluster.InActiveWindow),
+			cluster.WindowCheckedAt.Format(time.RFC3339),
+			cluster.Timezone,
+			cluster.WindowClock,
+			cluster.SecondaryThrottle,
+			strconv.FormatInt(cluster.ChunkSize, 10),
+			cluster.BalanceModel,
+		}},
+		document: cluster,
+		records:  []interface{}{cluster},
+	})
+}
+
+func zonesCommand(global *GlobalOptions) cli.Command {
+	return cli.Command{
+		Name:  "zones",
+		Usage: "show the chunk distribution of zoned collections within each zone and the chunks outside their zone",
+		Action: func(c *cli.Context) error {
+			format, err := global.outputFormat()
+			if err != nil {
+				return err
+			}
+			session, err := global.connect()
+			if err != nil {
+				return err
+			}
+			defer session.Close()
+
+			report, err := getReport(session, StatusOptions{
+				DatabasePatterns: global.DatabasePatterns,
+				AllDatabases:     global.AllDatabases,
+			})
+			if err != nil {
+				return cli.NewExitError(err.Error(), exitFatal)
+			}
+			if err := renderZones(os.Stdout, format, report); err != nil {
+				return cli.NewExitError(err.Error(), exitFatal)
+			}
+			if reportFailures(report) > 0 {
+				return cli.NewExitError("", exitPartial)
+			}
+			return nil
+		},
+	}
+}
+
+func historyCommand(global *GlobalOptions) cli.Command {
+	return cli.Command{
+		Name:  "history",
+		Usage: "summarise the chunk migrations, failures and splits recorded in config.changelog",
+		Flags: []cli.Flag{
+			cli.DurationFlag{
+				Name:  "since",
+				Value: 24 * time.Hour,
+				Usage: "how far back to read config.changelog",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			format, err := global.outputFormat()
+			if err != nil {
+				return err
+			}
+			since := c.Duration("since")
+			if since <= 0 {
+				return cli.NewExitError("--since must be positive", exitFatal)
+			}
+			session, err := global.connect()
+			if err != nil {
+				return err
+			}
+			defer session.Close()
+			configDb := session.DB("config")
+
+			databases, err := getDatabases(configDb, global.DatabasePatterns, global.AllDatabases)
+			if err != nil {
+				return cli.NewExitError("cannot read config.databases: "+err.Error(), exitFatal)
+			}
+			history, err := getHistory(configDb, databases, time.Now().Add(-since))
+			if err != nil {
+				return cli.NewExitError("cannot read config.changelog: "+err.Error(), exitFatal)
+			}
+			if err := renderHistory(os.Stdout, format, history); err != nil {
+				return cli.NewExitError(err.Error(), exitFatal)
+			}
+			return nil
+		},
+	}
+}
+
+func roundsCommand(global *GlobalOptions) cli.Command {
+	return cli.Command{
+		Name:  "rounds",
+		Usage: "show the recent balancer rounds from config.actionlog and whether the balancer is stuck, erroring or idle",
+		Flags: []cli.Flag{
+			cli.IntFlag{
+				Name:  "limit",
+				Value: 20,
+				Usage: "number of rounds to show",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			format, err := global.outputFormat()
+			if err != nil {
+				return err
+			}
+			limit := c.Int("limit")
+			if limit <= 0 {
+				return cli.NewExitError("--limit must be positive", exitFatal)
+			}
+			session, err := global.connect()
+			if err != nil {
+				return err
+			}
+			defer session.Close()
+
+			rounds, err := getBalancerRounds(session.DB("config"), limit)
+			if err != nil {
+				return cli.NewExitError("cannot read config.actionlog: "+err.Error(), exitFatal)
+			}
+			if err := renderBalancerRounds(os.Stdout, format, rounds); err != nil {
+				return cli.NewExitError(err.Error(), exitFatal)
+			}
+			return nil
+		},
+	}
+}
+
+func jumboCommand(global *GlobalOptions) cli.Command {
+	return cli.Command{
+		Name:  "jumbo",
+		Usage: "list every jumbo chunk with its shard key bounds",
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "sizes",
+				Usage: "estimate the size and document count of each jumbo chunk with dataSize",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			format, err := global.outputFormat()
+			if err != nil {
+				return err
+			}
+			session, err := global.connect()
+			if err != nil {
+				return err
+			}
+			defer session.Close()
+			configDb := session.DB("config")
+			sizes := c.Bool("sizes")
+
+			databases, err := getDatabases(configDb, global.DatabasePatterns, global.AllDatabases)
+			if err != nil {
+				return cli.NewExitError("cannot read config.databases: "+err.Error(), exitFatal)
+			}
+			cfCollections, err := getCollections(configDb, databases, false)
+			if err != nil {
+				return cli.NewExitError("cannot read config.collections: "+err.Error(), exitFatal)
+			}
+			byUUID, err := chunksKeyedByUUID(configDb)
+			if err != nil {
+				return cli.NewExitError("cannot read config.chunks: "+err.Error(), exitFatal)
+			}
+			jumbos, err := getJumboChunks(session, cfCollections, byUUID, sizes)
+			if err != nil {
+				return cli.NewExitError("cannot read config.chunks: "+err.Error(), exitFatal)
+			}
+			if err := renderJumboChunks(os.Stdout, format, sizes, jumbos); err != nil {
+				return cli.NewExitError(err.Error(), exitFatal)
+			}
+			failed := false
+			for _, j := range jumbos {
+				if j.Error != "" {
+					fmt.Fprintf(os.Stderr, "dataSize failed on %s %s: %s\n", j.Ns, j.Min, j.Error)
+					failed = true
+				}
+			}
+			if failed {
+				return cli.NewExitError("", exitPartial)
+			}
+			return nil
+		},
+	}
+}
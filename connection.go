@@ -0,0 +1,263 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	mgo "gopkg.in/mgo.v2"
+)
+
+// ConnectionOptions is the set of flags used to connect to mongos
+type ConnectionOptions struct {
+	URI                         string
+	Host                        string
+	Port                        int
+	Username                    string
+	Password                    string
+	AuthenticationDatabase      string
+	AuthenticationMechanism     string
+	SSL                         bool
+	SSLCAFile                   string
+	SSLPEMKeyFile               string
+	SSLPEMKeyPassword           string
+	SSLAllowInvalidCertificates bool
+}
+
+// dialTimeout is the same timeout mgo.Dial uses
+const dialTimeout = 10 * time.Second
+
+// tlsURIOptions are connection string options mgo.ParseURL does not understand
+// and that are applied to the TLS flags instead
+var tlsURIOptions = map[string]bool{
+	"ssl":                           true,
+	"tls":                           true,
+	"tlsCAFile":                     true,
+	"sslCAFile":                     true,
+	"tlsCertificateKeyFile":         true,
+	"sslPEMKeyFile":                 true,
+	"tlsCertificateKeyFilePassword": true,
+	"sslPEMKeyPassword":             true,
+	"tlsAllowInvalidCertificates":   true,
+	"sslAllowInvalidCertificates":   true,
+	"tlsInsecure":                   true,
+}
+
+// ignoredURIOptions are standard connection string options mgo.ParseURL
+// rejects and that do not matter to a read-only report on mongos
+var ignoredURIOptions = map[string]bool{
+	"appName":                  true,
+	"compressors":              true,
+	"zlibCompressionLevel":     true,
+	"connectTimeoutMS":         true,
+	"socketTimeoutMS":          true,
+	"serverSelectionTimeoutMS": true,
+	"heartbeatFrequencyMS":     true,
+	"localThresholdMS":         true,
+	"minPoolSize":              true,
+	"maxIdleTimeMS":            true,
+	"waitQueueTimeoutMS":       true,
+	"retryWrites":              true,
+	"retryReads":               true,
+	"w":                        true,
+	"wtimeoutMS":               true,
+	"journal":                  true,
+	"readPreference":           true,
+	"readPreferenceTags":       true,
+	"maxStalenessSeconds":      true,
+	"readConcernLevel":         true,
+}
+
+func getDialInfo(opts ConnectionOptions) (*mgo.DialInfo, error) {
+	var info *mgo.DialInfo
+	if opts.URI != "" {
+		if strings.HasPrefix(opts.URI, "mongodb+srv://") {
+			return nil, errors.New("mongodb+srv:// connection strings are not supported, use mongodb:// with the hosts of the seed list")
+		}
+		uri, err := stripURIOptions(opts.URI, &opts)
+		if err != nil {
+			return nil, err
+		}
+		info, err = mgo.ParseURL(uri)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		info = &mgo.DialInfo{Addrs: seedAddrs(opts.Host, opts.Port)}
+	}
+	info.Timeout = dialTimeout
+
+	// flags win over the connection string
+	if opts.Username != "" {
+		info.Username = opts.Username
+	}
+	if opts.Password != "" {
+		info.Password = opts.Password
+	}
+	if opts.AuthenticationDatabase != "" {
+		info.Source = opts.AuthenticationDatabase
+	}
+	if opts.AuthenticationMechanism != "" {
+		info.Mechanism = opts.AuthenticationMechanism
+	}
+
+	if opts.SSL {
+		tlsConfig, err := getTLSConfig(opts)
+		if err != nil {
+			return nil, err
+		}
+		info.DialServer = func(addr *mgo.ServerAddr) (net.Conn, error) {
+			dialer := &net.Dialer{Timeout: dialTimeout}
+			return tls.DialWithDialer(dialer, "tcp", addr.String(), tlsConfig)
+		}
+	}
+	return info, nil
+}
+
+// seedAddrs accepts "host", "host:port", "host1,host2" and "rs/host1,host2"
+func seedAddrs(host string, port int) []string {
+	if i := strings.Index(host, "/"); i != -1 {
+		host = host[i+1:]
+	}
+	var addrs []string
+	for _, h := range strings.Split(host, ",") {
+		h = strings.TrimSpace(h)
+		if h == "" {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(h); err != nil {
+			h = net.JoinHostPort(h, strconv.Itoa(port))
+		}
+		addrs = append(addrs, h)
+	}
+	return addrs
+}
+
+// stripURIOptions removes the options mgo.ParseURL does not understand from
+// uri, the TLS options are applied to opts and directConnection is mapped to
+// mgo's connect=direct
+func stripURIOptions(uri string, opts *ConnectionOptions) (string, error) {
+	c := strings.Index(uri, "?")
+	if c == -1 {
+		return uri, nil
+	}
+	var kept []string
+	for _, pair := range strings.FieldsFunc(uri[c+1:], func(r rune) bool { return r == '&' || r == ';' }) {
+		l := strings.SplitN(pair, "=", 2)
+		switch {
+		case len(l) != 2:
+			// mgo reports the malformed option
+			kept = append(kept, pair)
+			continue
+		case ignoredURIOptions[l[0]]:
+			continue
+		case l[0] == "directConnection":
+			direct, err := strconv.ParseBool(l[1])
+			if err != nil {
+				return "", errors.New("bad value for " + l[0] + ": " + l[1])
+			}
+			if direct {
+				kept = append(kept, "connect=direct")
+			}
+			continue
+		case !tlsURIOptions[l[0]]:
+			kept = append(kept, pair)
+			continue
+		}
+		value, err := url.QueryUnescape(l[1])
+		if err != nil {
+			return "", errors.New("bad value for " + l[0] + ": " + l[1])
+		}
+		switch l[0] {
+		case "ssl", "tls":
+			enabled, err := strconv.ParseBool(l[1])
+			if err != nil {
+				return "", errors.New("bad value for " + l[0] + ": " + l[1])
+			}
+			opts.SSL = opts.SSL || enabled
+		case "tlsCAFile", "sslCAFile":
+			if opts.SSLCAFile == "" {
+				opts.SSLCAFile = value
+			}
+		case "tlsCertificateKeyFile", "sslPEMKeyFile":
+			if opts.SSLPEMKeyFile == "" {
+				opts.SSLPEMKeyFile = value
+			}
+		case "tlsCertificateKeyFilePassword", "sslPEMKeyPassword":
+			if opts.SSLPEMKeyPassword == "" {
+				opts.SSLPEMKeyPassword = value
+			}
+		default:
+			insecure, err := strconv.ParseBool(l[1])
+			if err != nil {
+				return "", errors.New("bad value for " + l[0] + ": " + l[1])
+			}
+			opts.SSLAllowInvalidCertificates = opts.SSLAllowInvalidCertificates || insecure
+		}
+	}
+	if len(kept) == 0 {
+		return uri[:c], nil
+	}
+	return uri[:c+1] + strings.Join(kept, "&"), nil
+}
+
+func getTLSConfig(opts ConnectionOptions) (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: opts.SSLAllowInvalidCertificates}
+
+	if opts.SSLCAFile != "" {
+		caPEM, err := ioutil.ReadFile(opts.SSLCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, errors.New("no certificates found in " + opts.SSLCAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	// the PEM key file holds both the client certificate and its private key
+	if opts.SSLPEMKeyFile != "" {
+		keyPEM, err := ioutil.ReadFile(opts.SSLPEMKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		cert, err := loadClientCertificate(keyPEM, opts.SSLPEMKeyPassword)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+func loadClientCertificate(data []byte, password string) (tls.Certificate, error) {
+	var certPEM, keyPEM []byte
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type == "CERTIFICATE" {
+			certPEM = append(certPEM, pem.EncodeToMemory(block)...)
+			continue
+		}
+		if x509.IsEncryptedPEMBlock(block) {
+			der, err := x509.DecryptPEMBlock(block, []byte(password))
+			if err != nil {
+				return tls.Certificate{}, err
+			}
+			block = &pem.Block{Type: block.Type, Bytes: der}
+		}
+		keyPEM = append(keyPEM, pem.EncodeToMemory(block)...)
+	}
+	return tls.X509KeyPair(certPEM, keyPEM)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSeedAddrs(t *testing.T) {
+	tests := []struct {
+		host string
+		port int
+		want []string
+	}{
+		{"localhost", 27017, []string{"localhost:27017"}},
+		{"mongos1:27018", 27017, []string{"mongos1:27018"}},
+		{"mongos1,mongos2:27018", 27017, []string{"mongos1:27017", "mongos2:27018"}},
+		{"rs0/db1,db2", 27019, []string{"db1:27019", "db2:27019"}},
+		{" db1 , ,db2 ", 27017, []string{"db1:27017", "db2:27017"}},
+		{"::1", 27017, []string{"[::1]:27017"}},
+	}
+	for _, tt := range tests {
+		got := seedAddrs(tt.host, tt.port)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("seedAddrs(%q, %d) = %v, want %v", tt.host, tt.port, got, tt.want)
+		}
+	}
+}
+
+func TestStripURIOptions(t *testing.T) {
+	tests := []struct {
+		uri  string
+		want string
+		opts ConnectionOptions
+	}{
+		{
+			uri:  "mongodb://localhost:27017",
+			want: "mongodb://localhost:27017",
+		},
+		{
+			uri:  "mongodb://localhost:1/?retryWrites=true&w=majority",
+			want: "mongodb://localhost:1/",
+		},
+		{
+			uri:  "mongodb://u:p@h1,h2/admin?replicaSet=rs0&readPreference=secondary&appName=x;authSource=admin",
+			want: "mongodb://u:p@h1,h2/admin?replicaSet=rs0&authSource=admin",
+		},
+		{
+			uri:  "mongodb://h/?directConnection=true&journal=true",
+			want: "mongodb://h/?connect=direct",
+		},
+		{
+			uri:  "mongodb://h/?tls=true&tlsCAFile=%2Fetc%2Fca%20dir%2Fca.pem&tlsCertificateKeyFile=/etc/client.pem&tlsCertificateKeyFilePassword=p%26w",
+			want: "mongodb://h/",
+			opts: ConnectionOptions{SSL: true, SSLCAFile: "/etc/ca dir/ca.pem", SSLPEMKeyFile: "/etc/client.pem", SSLPEMKeyPassword: "p&w"},
+		},
+		{
+			uri:  "mongodb://h/?ssl=false&tlsInsecure=true&maxPoolSize=5",
+			want: "mongodb://h/?maxPoolSize=5",
+			opts: ConnectionOptions{SSLAllowInvalidCertificates: true},
+		},
+		{
+			uri:  "mongodb://h/?unknownOption=1",
+			want: "mongodb://h/?unknownOption=1",
+		},
+	}
+	for _, tt := range tests {
+		var opts ConnectionOptions
+		got, err := stripURIOptions(tt.uri, &opts)
+		if err != nil {
+			t.Errorf("stripURIOptions(%q) error: %v", tt.uri, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("stripURIOptions(%q) = %q, want %q", tt.uri, got, tt.want)
+		}
+		if opts != tt.opts {
+			t.Errorf("stripURIOptions(%q) options = %+v, want %+v", tt.uri, opts, tt.opts)
+		}
+	}
+}
+
+func TestStripURIOptionsBadValue(t *testing.T) {
+	for _, uri := range []string{
+		"mongodb://h/?tls=maybe",
+		"mongodb://h/?directConnection=yes",
+		"mongodb://h/?tlsCAFile=%zz",
+	} {
+		var opts ConnectionOptions
+		if _, err := stripURIOptions(uri, &opts); err == nil {
+			t.Errorf("stripURIOptions(%q) succeeded, want an error", uri)
+		}
+	}
+}
+
+func TestGetDialInfoURI(t *testing.T) {
+	info, err := getDialInfo(ConnectionOptions{URI: "mongodb://localhost:1/?retryWrites=true&w=majority"})
+	if err != nil {
+		t.Fatalf("getDialInfo error: %v", err)
+	}
+	if !reflect.DeepEqual(info.Addrs, []string{"localhost:1"}) {
+		t.Errorf("Addrs = %v, want [localhost:1]", info.Addrs)
+	}
+	if _, err := getDialInfo(ConnectionOptions{URI: "mongodb+srv://cluster.example.com/"}); err == nil {
+		t.Error("mongodb+srv:// is accepted, want an error")
+	}
+}
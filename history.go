@@ -0,0 +1,281 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	mapset "github.com/deckarep/golang-set"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// ChangelogEntry is mongo config.changelog document
+type ChangelogEntry struct {
+	Time    time.Time        `bson:"time"`
+	What    string           `bson:"what"`
+	Ns      string           `bson:"ns"`
+	Details ChangelogDetails `bson:"details"`
+}
+
+// ChangelogDetails is the part of the changelog details used by the history,
+// Number is the position of a chunk in a multi-split
+type ChangelogDetails struct {
+	Min    bson.D `bson:"min"`
+	From   string `bson:"from"`
+	To     string `bson:"to"`
+	Note   string `bson:"note"`
+	Errmsg string `bson:"errmsg"`
+	Number int    `bson:"number"`
+}
+
+// changelogEvents are the config.changelog events read by the history
+var changelogEvents = []string{
+	"moveChunk.start",
+	"moveChunk.commit",
+	"moveChunk.from",
+	"moveChunk.to",
+	"moveChunk.error",
+	"split",
+	"multi-split",
+}
+
+// Migration is a chunk migration seen in config.changelog, Started is nil
+// when the migration started before the window and Duration is in seconds
+type Migration struct {
+	Ns        string          `json:"ns"`
+	From      string          `json:"from"`
+	To        string          `json:"to"`
+	Min       json.RawMessage `json:"min"`
+	Started   *time.Time      `json:"started"`
+	Finished  time.Time       `json:"finished"`
+	Duration  *float64        `json:"duration"`
+	Committed bool            `json:"committed"`
+	Error     string          `json:"error,omitempty"`
+}
+
+// MigrationSummary is the migrations of a collection or of a pair of shards,
+// AveDuration is in seconds and nil when no duration is known
+type MigrationSummary struct {
+	Ns          string   `json:"ns,omitempty"`
+	From        string   `json:"from,omitempty"`
+	To          string   `json:"to,omitempty"`
+	Migrations  int      `json:"migrations"`
+	Failed      int      `json:"failed"`
+	Splits      int      `json:"splits"`
+	AveDuration *float64 `json:"aveDuration"`
+
+	durations float64
+	timed     int
+}
+
+// History is the balancing activity of the selected databases since Since
+type History struct {
+	Since       time.Time          `json:"since"`
+	Collections []MigrationSummary `json:"collections"`
+	ShardPairs  []MigrationSummary `json:"shardPairs"`
+	Failures    []Migration        `json:"failures"`
+	Migrations  []Migration        `json:"migrations"`
+}
+
+var migrationHeader = []string{
+	"finished",
+	"ns",
+	"from",
+	"to",
+	"min",
+	"duration(s)",
+	"committed",
+	"error",
+}
+
+// getHistory reads config.changelog since since and follows each migration
+// from its start to the donor's moveChunk.from or moveChunk.error
+func getHistory(db *mgo.Database, databases mapset.Set, since time.Time) (History, error) {
+	history := History{
+		Since:       since,
+		Collections: []MigrationSummary{},
+		ShardPairs:  []MigrationSummary{},
+		Failures:    []Migration{},
+		Migrations:  []Migration{},
+	}
+
+	var entries []ChangelogEntry
+	err := db.C("changelog").Find(bson.M{
+		"time": bson.M{"$gte": since},
+		"what": bson.M{"$in": changelogEvents},
+	}).Sort("time").All(&entries)
+	if err != nil {
+		return history, err
+	}
+
+	collections := make(map[string]*MigrationSummary)
+	collectionSummary := func(ns string) *MigrationSummary {
+		if collections[ns] == nil {
+			collections[ns] = &MigrationSummary{Ns: ns}
+		}
+		return collections[ns]
+	}
+
+	running := make(map[string]*Migration)
+	for _, e := range entries {
+		dbName, _ := splitNamespace(e.Ns)
+		if !databases.Contains(dbName) {
+			continue
+		}
+		minBound, err := marshalBounds(e.Details.Min)
+		if err != nil {
+			return history, err
+		}
+		key := e.Ns + string(minBound)
+
+		switch e.What {
+		case "split":
+			collectionSummary(e.Ns).Splits++
+		case "multi-split":
+			// one event per resulting chunk
+			if e.Details.Number == 1 {
+				collectionSummary(e.Ns).Splits++
+			}
+		case "moveChunk.start":
+			started := e.Time
+			running[key] = &Migration{
+				Ns:      e.Ns,
+				From:    e.Details.From,
+				To:      e.Details.To,
+				Min:     minBound,
+				Started: &started,
+			}
+		case "moveChunk.commit":
+			if m := running[key]; m != nil {
+				m.Committed = true
+			}
+		case "moveChunk.to":
+			// the recipient may fail first, the donor then aborts
+			if m := running[key]; m != nil && e.Details.Errmsg != "" {
+				m.Error = e.Details.Errmsg
+			}
+		case "moveChunk.from", "moveChunk.error":
+			m := running[key]
+			if m == nil {
+				m = &Migration{Ns: e.Ns, From: e.Details.From, To: e.Details.To, Min: minBound}
+			}
+			delete(running, key)
+			m.Finished = e.Time
+			if m.Started != nil {
+				duration := e.Time.Sub(*m.Started).Seconds()
+				m.Duration = &duration
+			}
+			switch {
+			case m.Error != "":
+			case e.Details.Errmsg != "":
+				m.Error = e.Details.Errmsg
+			case e.What == "moveChunk.error" || e.Details.Note == "aborted":
+				m.Error = "aborted"
+			case e.Details.Note == "success":
+				m.Committed = true
+			}
+			history.Migrations = append(history.Migrations, *m)
+		}
+	}
+
+	pairs := make(map[string]*MigrationSummary)
+	for _, m := range history.Migrations {
+		pair := m.From + " -> " + m.To
+		if pairs[pair] == nil {
+			pairs[pair] = &MigrationSummary{From: m.From, To: m.To}
+		}
+		for _, s := range []*MigrationSummary{collectionSummary(m.Ns), pairs[pair]} {
+			s.Migrations++
+			if m.Error != "" {
+				s.Failed++
+			}
+			if m.Duration != nil {
+				s.durations += *m.Duration
+				s.timed++
+			}
+		}
+		if m.Error != "" {
+			history.Failures = append(history.Failures, m)
+		}
+	}
+
+	history.Collections = sortedSummaries(collections)
+	history.ShardPairs = sortedSummaries(pairs)
+	return history, nil
+}
+
+// sortedSummaries sorts by key and averages the durations
+func sortedSummaries(summaries map[string]*MigrationSummary) []MigrationSummary {
+	keys := make([]string, 0, len(summaries))
+	for key := range summaries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	sorted := make([]MigrationSummary, len(keys))
+	for i, key := range keys {
+		s := summaries[key]
+		if s.timed > 0 {
+			ave := s.durations / float64(s.timed)
+			s.AveDuration = &ave
+		}
+		sorted[i] = *s
+	}
+	return sorted
+}
+
+// renderHistory renders the summaries as tables, the machine readable
+// formats but json get one record per migration
+func renderHistory(w io.Writer, format string, history History) error {
+	if format == formatTable || format == formatMarkdown {
+		markdown := format == formatMarkdown
+		table := newTable(w, markdown)
+		table.SetHeader([]string{"CollectionName", "migrations", "failed", "aveDuration(s)", "splits"})
+		for _, s := range history.Collections {
+			table.Append([]string{s.Ns, strconv.Itoa(s.Migrations), strconv.Itoa(s.Failed), formatOptionalRatio(s.AveDuration), strconv.Itoa(s.Splits)})
+		}
+		table.Render()
+		fmt.Fprintln(w)
+
+		table = newTable(w, markdown)
+		table.SetHeader([]string{"from", "to", "migrations", "failed", "aveDuration(s)"})
+		for _, s := range history.ShardPairs {
+			table.Append([]string{s.From, s.To, strconv.Itoa(s.Migrations), strconv.Itoa(s.Failed), formatOptionalRatio(s.AveDuration)})
+		}
+		table.Render()
+
+		if len(history.Failures) > 0 {
+			fmt.Fprintln(w)
+			table = newTable(w, markdown)
+			table.SetHeader(migrationHeader)
+			for _, m := range history.Failures {
+				table.Append(m.row())
+			}
+			table.Render()
+		}
+		return nil
+	}
+
+	v := view{header: migrationHeader, document: history}
+	for _, m := range history.Migrations {
+		v.rows = append(v.rows, m.row())
+		v.records = append(v.records, m)
+	}
+	return renderView(w, format, v)
+}
+
+func (m Migration) row() []string {
+	return []string{
+		m.Finished.Format(time.RFC3339),
+		m.Ns,
+		m.From,
+		m.To,
+		string(m.Min),
+		formatOptionalRatio(m.Duration),
+		formatBool(m.Committed),
+		m.Error,
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// JumboChunk is a chunk flagged jumbo, the sizes are only set on request
+type JumboChunk struct {
+	Ns       string          `json:"ns"`
+	Shard    string          `json:"shard"`
+	Min      json.RawMessage `json:"min"`
+	Max      json.RawMessage `json:"max"`
+	Lastmod  string          `json:"lastmod"`
+	DataSize *int64          `json:"dataSize,omitempty"`
+	Objects  *int64          `json:"objects,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+var jumboHeader = []string{
+	"ns",
+	"shard",
+	"min",
+	"max",
+	"lastmod",
+}
+
+// getJumboChunks lists the jumbo chunks of collections, with sizes it also
+// runs an estimated dataSize on each of them
+func getJumboChunks(session *mgo.Session, collections CollectionSlice, byUUID bool, sizes bool) ([]JumboChunk, error) {
+	configDb := session.DB("config")
+
+	byKey := make(map[string]Collection)
+	values := make([]interface{}, len(collections))
+	for i, c := range collections {
+		if byUUID {
+			byKey[string(c.UUID.Data)] = c
+			values[i] = c.UUID
+		} else {
+			byKey[c.ID] = c
+			values[i] = c.ID
+		}
+	}
+	key := "ns"
+	if byUUID {
+		key = "uuid"
+	}
+
+	var chunks ChunkSlice
+	err := configDb.C("chunks").Find(bson.M{key: bson.M{"$in": values}, "jumbo": true}).All(&chunks)
+	if err != nil {
+		return nil, err
+	}
+
+	jumbos := make([]JumboChunk, len(chunks))
+	for i, chunk := range chunks {
+		collection := byKey[chunk.Ns]
+		if byUUID {
+			collection = byKey[string(chunk.UUID.Data)]
+		}
+		minBound, err := marshalBounds(chunk.Min)
+		if err != nil {
+			return nil, err
+		}
+		maxBound, err := marshalBounds(chunk.Max)
+		if err != nil {
+			return nil, err
+		}
+		jumbos[i] = JumboChunk{
+			Ns:      collection.ID,
+			Shard:   chunk.Shard,
+			Min:     minBound,
+			Max:     maxBound,
+			Lastmod: formatLastmod(chunk.Lastmod),
+		}
+
+		if sizes {
+			dataSize, err := getChunkDataSize(session, collection, chunk, true)
+			if err != nil {
+				jumbos[i].Error = err.Error()
+				continue
+			}
+			jumbos[i].DataSize = &dataSize.Size
+			jumbos[i].Objects = &dataSize.NumObjects
+		}
+	}
+
+	sort.SliceStable(jumbos, func(i int, j int) bool {
+		return jumbos[i].Ns < jumbos[j].Ns
+	})
+	return jumbos, nil
+}
+
+// marshalBounds renders shard key bounds as extended JSON in key order
+func marshalBounds(bounds bson.D) (json.RawMessage, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, e := range bounds {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, err := json.Marshal(e.Name)
+		if err != nil {
+			return nil, err
+		}
+		var value []byte
+		if d, ok := e.Value.(bson.D); ok {
+			value, err = marshalBounds(d)
+		} else {
+			value, err = bson.MarshalJSON(e.Value)
+		}
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(bytes.TrimSpace(value))
+	}
+	buf.WriteByte('}')
+	return json.RawMessage(buf.Bytes()), nil
+}
+
+// formatLastmod renders a chunk version as major|minor like sh.status()
+func formatLastmod(lastmod bson.MongoTimestamp) string {
+	return fmt.Sprintf("%d|%d", uint64(lastmod)>>32, uint64(lastmod)&0xffffffff)
+}
+
+func renderJumboChunks(w io.Writer, format string, sizes bool, jumbos []JumboChunk) error {
+	v := view{
+		header:    jumboHeader,
+		csvHeader: jumboHeader,
+		document: struct {
+			JumboChunks []JumboChunk `json:"jumboChunks"`
+		}{jumbos},
+	}
+	if sizes {
+		v.header = append(append([]string{}, jumboHeader...), "size(KB)", "Objs")
+		v.csvHeader = append(append([]string{}, jumboHeader...), "size(B)", "Objs", "error")
+	}
+	for _, j := range jumbos {
+		v.rows = append(v.rows, j.row(sizes, formatKB))
+		v.csvRows = append(v.csvRows, j.csvRow(sizes))
+		v.records = append(v.records, j)
+	}
+	return renderView(w, format, v)
+}
+
+func (j JumboChunk) row(sizes bool, formatSize func(int64) string) []string {
+	row := []string{j.Ns, j.Shard, string(j.Min), string(j.Max), j.Lastmod}
+	if !sizes {
+		return row
+	}
+	if j.Error != "" {
+		return append(row, "ERROR", "")
+	}
+	return append(row, formatOptional(j.DataSize, formatSize), formatOptionalInt64(j.Objects))
+}
+
+// csvRow leaves the sizes of a failed dataSize empty and adds its error
+func (j JumboChunk) csvRow(sizes bool) []string {
+	if !sizes {
+		return j.row(false, formatBytes)
+	}
+	if j.Error != "" {
+		return append(j.row(false, formatBytes), "", "", j.Error)
+	}
+	return append(j.row(true, formatBytes), "")
+}
@@ -0,0 +1,71 @@
+package main
+
+import "testing"
+
+func TestSplitNamespace(t *testing.T) {
+	tests := []struct {
+		ns         string
+		db         string
+		collection string
+	}{
+		{"test.users", "test", "users"},
+		{"app.events.2024", "app", "events.2024"},
+		{"db", "db", ""},
+		{"db.", "db", ""},
+	}
+	for _, tt := range tests {
+		db, collection := splitNamespace(tt.ns)
+		if db != tt.db || collection != tt.collection {
+			t.Errorf("splitNamespace(%q) = %q, %q, want %q, %q", tt.ns, db, collection, tt.db, tt.collection)
+		}
+	}
+}
+
+func TestIdealChunksPerShard(t *testing.T) {
+	tests := []struct {
+		chunks int
+		shards int
+		want   int
+	}{
+		{0, 3, 0},
+		{0, 0, 0},
+		{5, 0, 0},
+		{1, 3, 1},
+		{3, 3, 1},
+		{4, 3, 2},
+		{10, 3, 4},
+	}
+	for _, tt := range tests {
+		if got := idealChunksPerShard(tt.chunks, tt.shards); got != tt.want {
+			t.Errorf("idealChunksPerShard(%d, %d) = %d, want %d", tt.chunks, tt.shards, got, tt.want)
+		}
+	}
+}
+
+func TestRowsWithoutChunkSizes(t *testing.T) {
+	// no chunks or no documents leave the chunk sizes nil
+	tests := []CollectionStatus{
+		{Name: "test.empty", Objects: 0, Chunks: 3},
+		{Name: "test.nochunks", Objects: 10, Chunks: 0},
+	}
+	for _, c := range tests {
+		for name, row := range map[string][]string{"tableRow": c.tableRow(), "csvRow": c.csvRow()} {
+			header := tableHeader
+			if name == "csvRow" {
+				header = csvHeader
+			}
+			if len(row) != len(header) {
+				t.Fatalf("%s of %s has %d columns, want %d", name, c.Name, len(row), len(header))
+			}
+			for i, column := range header {
+				switch column {
+				case "aveChunkSize(KB)", "aveChunkSize(B)", "remainChunksSize(KB)", "remainChunksSize(B)",
+					"oversizedChunks(est)", "aveChunkSize/chunksize":
+					if row[i] != "n/a" {
+						t.Errorf("%s of %s: %s = %q, want n/a", name, c.Name, column, row[i])
+					}
+				}
+			}
+		}
+	}
+}
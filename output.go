@@ -0,0 +1,526 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// output formats
+const (
+	formatTable    = "table"
+	formatMarkdown = "markdown"
+	formatJSON     = "json"
+	formatNDJSON   = "ndjson"
+	formatCSV      = "csv"
+	formatTSV      = "tsv"
+)
+
+var validFormats = map[string]bool{
+	formatTable:    true,
+	formatMarkdown: true,
+	formatJSON:     true,
+	formatNDJSON:   true,
+	formatCSV:      true,
+	formatTSV:      true,
+}
+
+var tableHeader = []string{
+	"CollectionName",
+	"Objs",
+	"chunks",
+	"aveChunkSize(KB)",
+	"AllDataSize(MB)",
+	"idealChunksPerShards",
+	"remainChunks",
+	"remainChunksSize(KB)",
+	"Jumbos",
+	"oversizedChunks(est)",
+	"aveChunkSize/chunksize",
+	"warning",
+	"balancer",
+}
+
+// csvHeader has the same columns as tableHeader with sizes in bytes
+var csvHeader = []string{
+	"CollectionName",
+	"Objs",
+	"chunks",
+	"aveChunkSize(B)",
+	"AllDataSize(B)",
+	"idealChunksPerShards",
+	"remainChunks",
+	"remainChunksSize(B)",
+	"Jumbos",
+	"oversizedChunks(est)",
+	"aveChunkSize/chunksize",
+	"warning",
+	"balancer",
+}
+
+// extraColumns is an optional group of columns appended to the collection
+// rows, the sizes of row are rendered with formatSize
+type extraColumns struct {
+	tableHeader []string
+	csvHeader   []string
+	row         func(c CollectionStatus, formatSize func(int64) string) []string
+}
+
+var metadataColumns = extraColumns{
+	tableHeader: []string{"shardKey", "unique", "dropped", "lastmodEpoch", "defaultCollation"},
+	csvHeader:   []string{"shardKey", "unique", "dropped", "lastmodEpoch", "defaultCollation"},
+	row: func(c CollectionStatus, formatSize func(int64) string) []string {
+		return []string{
+			string(c.ShardKey),
+			formatBool(c.Unique),
+			formatBool(c.Dropped),
+			c.LastmodEpoch,
+			string(c.DefaultCollation),
+		}
+	},
+}
+
+var storageColumns = extraColumns{
+	tableHeader: []string{"storageSize(MB)", "indexSize(MB)", "indexes", "compression"},
+	csvHeader:   []string{"storageSize(B)", "indexSize(B)", "indexes", "compression"},
+	row: func(c CollectionStatus, formatSize func(int64) string) []string {
+		return []string{
+			formatSize(c.StorageSize),
+			formatSize(c.IndexSize),
+			strconv.Itoa(c.Indexes),
+			formatOptionalRatio(c.CompressionRatio),
+		}
+	},
+}
+
+// thresholdColumns render the migration threshold in chunks, or as a size
+// with the bytes balance model
+var thresholdColumns = extraColumns{
+	tableHeader: []string{"maxMinChunks", "maxMinDataSize(MB)", "migrationThreshold", "balancerActs"},
+	csvHeader:   []string{"maxMinChunks", "maxMinDataSize(B)", "migrationThreshold", "balancerActs"},
+	row: func(c CollectionStatus, formatSize func(int64) string) []string {
+		threshold := strconv.FormatInt(c.MigrationThreshold, 10)
+		if c.BalanceModel == balanceModelBytes {
+			threshold = formatSize(c.MigrationThreshold)
+		}
+		return []string{
+			strconv.Itoa(c.ChunkSpread),
+			formatSize(c.DataSpread),
+			threshold,
+			formatBool(c.BalancerActs),
+		}
+	},
+}
+
+var imbalanceColumns = extraColumns{
+	tableHeader: []string{"minShardChunks", "maxShardChunks", "stddev", "cv", "dataToMove(%)"},
+	csvHeader:   []string{"minShardChunks", "maxShardChunks", "stddev", "cv", "dataToMove(%)"},
+	row: func(c CollectionStatus, formatSize func(int64) string) []string {
+		return []string{
+			strconv.Itoa(c.MinShardChunks),
+			strconv.Itoa(c.MaxShardChunks),
+			strconv.FormatFloat(c.ChunksStdDev, 'f', 2, 64),
+			formatOptionalRatio(c.ChunksCV),
+			formatOptionalRatio(c.DataToMove),
+		}
+	},
+}
+
+// extraColumns are the optional column groups requested for the report
+func (r Report) extraColumns() []extraColumns {
+	var extras []extraColumns
+	if r.Thresholds {
+		extras = append(extras, thresholdColumns)
+	}
+	if r.Imbalance {
+		extras = append(extras, imbalanceColumns)
+	}
+	if r.Storage {
+		extras = append(extras, storageColumns)
+	}
+	if r.Metadata {
+		extras = append(extras, metadataColumns)
+	}
+	return extras
+}
+
+// withExtraHeader appends the headers of the extra columns to header
+func (r Report) withExtraHeader(header []string, csv bool) []string {
+	header = append([]string{}, header...)
+	for _, extra := range r.extraColumns() {
+		if csv {
+			header = append(header, extra.csvHeader...)
+		} else {
+			header = append(header, extra.tableHeader...)
+		}
+	}
+	return header
+}
+
+// withExtraColumns appends the extra columns of c to row, blank for failed
+// collections and subtotal rows
+func (r Report) withExtraColumns(row []string, c *CollectionStatus, formatSize func(int64) string) []string {
+	for _, extra := range r.extraColumns() {
+		if c == nil || c.Error != "" {
+			row = append(row, make([]string, len(extra.tableHeader))...)
+			continue
+		}
+		row = append(row, extra.row(*c, formatSize)...)
+	}
+	return row
+}
+
+func render(w io.Writer, format string, report Report) error {
+	switch format {
+	case formatTable, formatMarkdown:
+		renderClusterStatus(w, format == formatMarkdown, report.Cluster)
+		renderTable(w, format == formatMarkdown, report)
+		if report.Imbalance {
+			fmt.Fprintf(w, "\nimbalance score: %s%%\n", formatOptionalRatio(report.ImbalanceScore))
+		}
+		if report.ExactChunkSizes {
+			fmt.Fprintln(w)
+			renderChunkSizes(w, format == formatMarkdown, report)
+		}
+		return nil
+	case formatJSON:
+		return renderJSON(w, report)
+	case formatNDJSON:
+		return renderNDJSON(w, report.Collections)
+	case formatCSV:
+		return renderCSV(w, ',', report)
+	case formatTSV:
+		return renderCSV(w, '\t', report)
+	}
+	return fmt.Errorf("unknown output format: %s", format)
+}
+
+// view is a report rendered as one table, one JSON document, one JSON line
+// per record or CSV. The CSV header and rows default to the table ones, the
+// footer follows the table.
+type view struct {
+	header    []string
+	rows      [][]string
+	csvHeader []string
+	csvRows   [][]string
+	footer    string
+	document  interface{}
+	records   []interface{}
+}
+
+func renderView(w io.Writer, format string, v view) error {
+	switch format {
+	case formatTable, formatMarkdown:
+		table := newTable(w, format == formatMarkdown)
+		table.SetHeader(v.header)
+		table.AppendBulk(v.rows)
+		table.Render()
+		if v.footer != "" {
+			fmt.Fprintf(w, "\n%s\n", v.footer)
+		}
+		return nil
+	case formatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v.document)
+	case formatNDJSON:
+		enc := json.NewEncoder(w)
+		for _, record := range v.records {
+			if err := enc.Encode(record); err != nil {
+				return err
+			}
+		}
+		return nil
+	case formatCSV, formatTSV:
+		writer := csv.NewWriter(w)
+		if format == formatTSV {
+			writer.Comma = '\t'
+		}
+		header, rows := v.header, v.rows
+		if v.csvHeader != nil {
+			header, rows = v.csvHeader, v.csvRows
+		}
+		if err := writer.Write(header); err != nil {
+			return err
+		}
+		return writer.WriteAll(rows)
+	}
+	return fmt.Errorf("unknown output format: %s", format)
+}
+
+func newTable(w io.Writer, markdown bool) *tablewriter.Table {
+	table := tablewriter.NewWriter(w)
+
+	// Markdown Output
+	if markdown {
+		table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
+		table.SetCenterSeparator("|")
+	}
+	return table
+}
+
+func renderTable(w io.Writer, markdown bool, report Report) {
+	table := newTable(w, markdown)
+
+	if report.PerShard {
+		renderPerShardTable(table, report)
+		return
+	}
+
+	if !report.MultiDatabases {
+		table.SetHeader(report.withExtraHeader(tableHeader, false))
+		for i, c := range report.Collections {
+			table.Append(report.withExtraColumns(c.tableRow(), &report.Collections[i], formatMB))
+		}
+		table.Render()
+		return
+	}
+
+	// one group of rows and a subtotal row per database
+	table.SetHeader(append([]string{"Database"}, report.withExtraHeader(tableHeader, false)...))
+	i := 0
+	for _, d := range report.Databases {
+		for ; i < len(report.Collections) && report.Collections[i].Database == d.Database; i++ {
+			row := report.withExtraColumns(report.Collections[i].tableRow(), &report.Collections[i], formatMB)
+			table.Append(append([]string{d.Database}, row...))
+		}
+		table.Append(append([]string{d.Database}, report.withExtraColumns(d.tableRow(), nil, formatMB)...))
+	}
+	table.Render()
+}
+
+func (c CollectionStatus) tableRow() []string {
+	if c.Error != "" {
+		return errorRow(c.Name, len(tableHeader))
+	}
+	return []string{
+		c.Name,
+		strconv.Itoa(c.Objects),
+		strconv.Itoa(c.Chunks),
+		formatOptional(c.AveChunkSize, formatKB),
+		formatMB(c.AllDataSize),
+		strconv.Itoa(c.IdealChunksPerShard),
+		strconv.Itoa(c.RemainChunks),
+		formatOptional(c.RemainChunksSize, formatKB),
+		strconv.Itoa(c.JumboChunks),
+		formatOptionalInt(c.OversizedChunks),
+		formatOptionalRatio(c.ChunkSizeRatio),
+		c.Warning,
+		formatBool(c.Balancer),
+	}
+}
+
+// csvRow expects a collection without error, see renderCSV
+func (c CollectionStatus) csvRow() []string {
+	return []string{
+		c.Name,
+		strconv.Itoa(c.Objects),
+		strconv.Itoa(c.Chunks),
+		formatOptional(c.AveChunkSize, formatBytes),
+		formatBytes(c.AllDataSize),
+		strconv.Itoa(c.IdealChunksPerShard),
+		strconv.Itoa(c.RemainChunks),
+		formatOptional(c.RemainChunksSize, formatBytes),
+		strconv.Itoa(c.JumboChunks),
+		formatOptionalInt(c.OversizedChunks),
+		formatOptionalRatio(c.ChunkSizeRatio),
+		c.Warning,
+		formatBool(c.Balancer),
+	}
+}
+
+// renderPerShardTable renders the collection × shard matrix and a total row
+func renderPerShardTable(table *tablewriter.Table, report Report) {
+	header := perShardHeader(report.Shards, "size(MB)")
+	totalChunks := 0
+	shardChunks := make([]int, len(report.Shards))
+	shardObjects := make([]*int64, len(report.Shards))
+	shardDataSize := make([]int64, len(report.Shards))
+	for _, c := range report.Collections {
+		row := c.perShardRow(formatMB)
+		if report.MultiDatabases {
+			row = append([]string{c.Database}, row...)
+		}
+		table.Append(row)
+
+		totalChunks += c.Chunks
+		for j, s := range c.Shards {
+			shardChunks[j] += s.Chunks
+			shardDataSize[j] += s.DataSize
+			if s.Objects != nil {
+				if shardObjects[j] == nil {
+					shardObjects[j] = new(int64)
+				}
+				*shardObjects[j] += *s.Objects
+			}
+		}
+	}
+	footer := []string{"total", strconv.Itoa(totalChunks)}
+	for j := range report.Shards {
+		footer = append(footer, strconv.Itoa(shardChunks[j]), formatOptionalInt64(shardObjects[j]), formatMB(shardDataSize[j]))
+	}
+	if report.MultiDatabases {
+		header = append([]string{"Database"}, header...)
+		footer = append([]string{""}, footer...)
+	}
+	table.SetHeader(header)
+	table.Append(footer)
+	table.Render()
+}
+
+func perShardHeader(shards []string, sizeColumn string) []string {
+	header := []string{"CollectionName", "chunks"}
+	for _, shard := range shards {
+		header = append(header, shard+" chunks", shard+" Objs", shard+" "+sizeColumn)
+	}
+	return header
+}
+
+func (c CollectionStatus) perShardRow(formatSize func(int64) string) []string {
+	if c.Error != "" {
+		return errorRow(c.Name, 2+3*len(c.Shards))
+	}
+	row := []string{c.Name, strconv.Itoa(c.Chunks)}
+	for _, s := range c.Shards {
+		row = append(row, strconv.Itoa(s.Chunks), formatOptionalInt64(s.Objects), formatSize(s.DataSize))
+	}
+	return row
+}
+
+// errorRow marks a failed collection, the error itself is printed on stderr
+func errorRow(name string, columns int) []string {
+	row := make([]string, columns)
+	row[0] = name
+	row[1] = "ERROR"
+	return row
+}
+
+func (d DatabaseSummary) tableRow() []string {
+	return []string{
+		"(subtotal)",
+		strconv.Itoa(d.Objects),
+		strconv.Itoa(d.Chunks),
+		"",
+		formatMB(d.AllDataSize),
+		"",
+		strconv.Itoa(d.RemainChunks),
+		formatKB(d.RemainChunksSize),
+		strconv.Itoa(d.JumboChunks),
+		"",
+		"",
+		"",
+		"",
+	}
+}
+
+func renderJSON(w io.Writer, report Report) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+func renderNDJSON(w io.Writer, collections []CollectionStatus) error {
+	enc := json.NewEncoder(w)
+	for _, c := range collections {
+		if err := enc.Encode(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func renderCSV(w io.Writer, comma rune, report Report) error {
+	writer := csv.NewWriter(w)
+	writer.Comma = comma
+	header := report.withExtraHeader(csvHeader, true)
+	if report.PerShard {
+		header = perShardHeader(report.Shards, "size(B)")
+	}
+	columns := len(header)
+	if report.MultiDatabases {
+		header = append([]string{"Database"}, header...)
+	}
+	if err := writer.Write(append(header, "error")); err != nil {
+		return err
+	}
+	// a failed collection keeps its numeric cells empty for importers
+	for i, c := range report.Collections {
+		var row []string
+		switch {
+		case c.Error != "":
+			row = make([]string, columns)
+			row[0] = c.Name
+		case report.PerShard:
+			row = c.perShardRow(formatBytes)
+		default:
+			row = report.withExtraColumns(c.csvRow(), &report.Collections[i], formatBytes)
+		}
+		if report.MultiDatabases {
+			row = append([]string{c.Database}, row...)
+		}
+		if err := writer.Write(append(row, c.Error)); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func formatBool(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+func formatOptionalBool(b *bool) string {
+	if b == nil {
+		return "n/a"
+	}
+	return formatBool(*b)
+}
+
+// formatOptional renders a nil size as "n/a"
+func formatOptional(size *int64, format func(int64) string) string {
+	if size == nil {
+		return "n/a"
+	}
+	return format(*size)
+}
+
+func formatOptionalInt(n *int) string {
+	if n == nil {
+		return "n/a"
+	}
+	return strconv.Itoa(*n)
+}
+
+func formatOptionalInt64(n *int64) string {
+	if n == nil {
+		return "n/a"
+	}
+	return strconv.FormatInt(*n, 10)
+}
+
+func formatOptionalRatio(ratio *float64) string {
+	if ratio == nil {
+		return "n/a"
+	}
+	return strconv.FormatFloat(*ratio, 'f', 2, 64)
+}
+
+func formatBytes(size int64) string {
+	return strconv.FormatInt(size, 10)
+}
+
+func formatKB(size int64) string {
+	return strconv.FormatFloat(float64(size)/float64(1024), 'f', 2, 64)
+}
+
+func formatMB(size int64) string {
+	return strconv.FormatFloat(float64(size)/float64(1024)/float64(1024), 'f', 2, 64)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+)
+
+func TestRenderCSVError(t *testing.T) {
+	size := int64(1024)
+	report := Report{
+		Collections: []CollectionStatus{
+			{Name: "test.ok", Objects: 10, Chunks: 2, AveChunkSize: &size, RemainChunksSize: &size},
+			{Name: "test.failed", Error: "not authorized"},
+		},
+	}
+	for _, perShard := range []bool{false, true} {
+		report.PerShard = perShard
+		var buf bytes.Buffer
+		if err := renderCSV(&buf, ',', report); err != nil {
+			t.Fatalf("renderCSV error: %v", err)
+		}
+		records, err := csv.NewReader(&buf).ReadAll()
+		if err != nil {
+			t.Fatalf("renderCSV output is not CSV: %v", err)
+		}
+		header, failed := records[0], records[2]
+		if header[len(header)-1] != "error" {
+			t.Errorf("last column = %q, want error", header[len(header)-1])
+		}
+		if records[1][len(header)-1] != "" {
+			t.Errorf("error of a collection without error = %q", records[1][len(header)-1])
+		}
+		if failed[0] != "test.failed" || failed[len(failed)-1] != "not authorized" {
+			t.Errorf("failed row = %q", failed)
+		}
+		for i, cell := range failed[1 : len(failed)-1] {
+			if cell != "" {
+				t.Errorf("column %s of a failed collection = %q, want empty", header[i+1], cell)
+			}
+		}
+	}
+}
+
+func TestRenderView(t *testing.T) {
+	v := view{
+		header:    []string{"name", "size(KB)"},
+		rows:      [][]string{{"a", "1.00"}},
+		csvHeader: []string{"name", "size(B)"},
+		csvRows:   [][]string{{"a", "1024"}},
+		footer:    "done",
+		document:  map[string]int{"a": 1024},
+		records:   []interface{}{map[string]int{"a": 1024}, map[string]int{"b": 0}},
+	}
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{formatCSV, "name,size(B)\na,1024\n"},
+		{formatTSV, "name\tsize(B)\na\t1024\n"},
+		{formatJSON, "{\n  \"a\": 1024\n}\n"},
+		{formatNDJSON, "{\"a\":1024}\n{\"b\":0}\n"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := renderView(&buf, tt.format, v); err != nil {
+			t.Fatalf("renderView(%s) error: %v", tt.format, err)
+		}
+		if buf.String() != tt.want {
+			t.Errorf("renderView(%s) = %q, want %q", tt.format, buf.String(), tt.want)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := renderView(&buf, formatTable, v); err != nil {
+		t.Fatalf("renderView(table) error: %v", err)
+	}
+	if out := buf.String(); !strings.Contains(out, "SIZE(KB)") || !strings.HasSuffix(out, "\ndone\n") {
+		t.Errorf("renderView(table) = %q", out)
+	}
+	if err := renderView(&buf, "xml", v); err == nil {
+		t.Error("renderView(xml) succeeded, want an error")
+	}
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+	"time"
+)
+
+// CollectionStatus is the chunk status of a sharded collection, sizes are in
+// bytes and the chunk sizes are nil when there are no chunks or no documents.
+// On a zoned collection IdealChunksPerShard ignores the zones while
+// RemainChunks follows them, Zones has the ideal of each zone. With the bytes
+// balance model RemainChunksSize is the data beyond IdealDataPerShard and
+// RemainChunks the chunks it takes at the average chunk size.
+// MigrationThreshold is in chunks, or in bytes with the bytes balance model.
+// DataToMove is the percent of the data in RemainChunksSize.
+type CollectionStatus struct {
+	Database            string           `json:"database"`
+	Name                string           `json:"collection"`
+	Objects             int              `json:"objects"`
+	Chunks              int              `json:"chunks"`
+	AveChunkSize        *int64           `json:"aveChunkSize"`
+	AllDataSize         int64            `json:"allDataSize"`
+	BalanceModel        string           `json:"balanceModel"`
+	IdealChunksPerShard int              `json:"idealChunksPerShard"`
+	IdealDataPerShard   *int64           `json:"idealDataPerShard"`
+	RemainChunks        int              `json:"remainChunks"`
+	RemainChunksSize    *int64           `json:"remainChunksSize"`
+	JumboChunks         int              `json:"jumboChunks"`
+	OversizedChunks     *int             `json:"oversizedChunks"`
+	ChunkSizeRatio      *float64         `json:"chunkSizeRatio"`
+	Warning             string           `json:"warning,omitempty"`
+	Balancer            bool             `json:"balancer"`
+	ChunkSpread         int              `json:"chunkSpread"`
+	DataSpread          int64            `json:"dataSpread"`
+	MigrationThreshold  int64            `json:"migrationThreshold"`
+	BalancerActs        bool             `json:"balancerActs"`
+	MinShardChunks      int              `json:"minShardChunks"`
+	MaxShardChunks      int              `json:"maxShardChunks"`
+	ChunksStdDev        float64          `json:"chunksStdDev"`
+	ChunksCV            *float64         `json:"chunksCV"`
+	DataToMove          *float64         `json:"dataToMove"`
+	StorageSize         int64            `json:"storageSize"`
+	IndexSize           int64            `json:"indexSize"`
+	Indexes             int              `json:"indexes"`
+	IndexSizes          map[string]int64 `json:"indexSizes"`
+	CompressionRatio    *float64         `json:"compressionRatio"`
+	ShardKey            json.RawMessage  `json:"shardKey"`
+	Unique              bool             `json:"unique"`
+	Dropped             bool             `json:"dropped"`
+	LastmodEpoch        string           `json:"lastmodEpoch"`
+	DefaultCollation    json.RawMessage  `json:"defaultCollation,omitempty"`
+	Shards              []ShardChunks    `json:"shards"`
+	DataSkew            *float64         `json:"dataSkew"`
+	Zones               []ZoneChunks     `json:"zones,omitempty"`
+	ZoneViolations      int              `json:"zoneViolations"`
+	ChunkSizes          *ChunkSizes      `json:"chunkSizes,omitempty"`
+	Error               string           `json:"error,omitempty"`
+	Delta               *CollectionDelta `json:"delta,omitempty"`
+}
+
+// ShardChunks is the part of a collection on a shard. DataSize and Objects
+// come from the collStats breakdown by shard, without it DataSize is estimated
+// from the average chunk size and Objects is nil. The exact chunk sizes
+// replace DataSize when requested.
+type ShardChunks struct {
+	Shard    string `json:"shard"`
+	Chunks   int    `json:"chunks"`
+	Objects  *int64 `json:"objects"`
+	DataSize int64  `json:"dataSize"`
+}
+
+// dataToMigrate returns the average data size per shard and how much data
+// the shards hold beyond it
+func dataToMigrate(shards []ShardChunks) (int64, int64) {
+	if len(shards) == 0 {
+		return 0, 0
+	}
+	var total int64
+	for _, s := range shards {
+		total += s.DataSize
+	}
+	ideal := total / int64(len(shards))
+	var remain int64
+	for _, s := range shards {
+		if s.DataSize > ideal {
+			remain += s.DataSize - ideal
+		}
+	}
+	return ideal, remain
+}
+
+// estimateChunkSizes gives every chunk the average chunk size of its shard
+func estimateChunkSizes(chunks ChunkSlice, shards []ShardChunks) []int64 {
+	average := make(map[string]int64)
+	for _, s := range shards {
+		if s.Chunks > 0 {
+			average[s.Shard] = s.DataSize / int64(s.Chunks)
+		}
+	}
+	sizes := make([]int64, len(chunks))
+	for i, chunk := range chunks {
+		sizes[i] = average[chunk.Shard]
+	}
+	return sizes
+}
+
+// chunkMigrationThreshold is the chunk count difference between the most and
+// the least loaded shard from which the balancer before MongoDB 6.0 migrates
+func chunkMigrationThreshold(chunks int) int64 {
+	switch {
+	case chunks < 20:
+		return 2
+	case chunks < 80:
+		return 4
+	}
+	return 8
+}
+
+// dataMigrationThresholdRatio is the data size difference between the most and
+// the least loaded shard, in chunk sizes, from which MongoDB 6.0+ migrates
+const dataMigrationThresholdRatio = 3
+
+// shardSpread returns the fewest and the most chunks on a shard and the data
+// size difference between the most and the least loaded shard
+func shardSpread(shards []ShardChunks) (int, int, int64) {
+	if len(shards) == 0 {
+		return 0, 0, 0
+	}
+	minChunks, maxChunks := shards[0].Chunks, shards[0].Chunks
+	minData, maxData := shards[0].DataSize, shards[0].DataSize
+	for _, s := range shards[1:] {
+		if s.Chunks < minChunks {
+			minChunks = s.Chunks
+		}
+		if s.Chunks > maxChunks {
+			maxChunks = s.Chunks
+		}
+		if s.DataSize < minData {
+			minData = s.DataSize
+		}
+		if s.DataSize > maxData {
+			maxData = s.DataSize
+		}
+	}
+	return minChunks, maxChunks, maxData - minData
+}
+
+// checkMigrationThreshold returns the migration threshold of the balance
+// model, in chunks or bytes, and whether the chunk or data difference between
+// the most and the least loaded shard reaches it
+func checkMigrationThreshold(chunkSpread int, dataSpread int64, chunks int, model string, maxChunkSize int64) (int64, bool) {
+	if model == balanceModelBytes {
+		threshold := dataMigrationThresholdRatio * maxChunkSize
+		return threshold, dataSpread >= threshold
+	}
+	threshold := chunkMigrationThreshold(chunks)
+	return threshold, int64(chunkSpread) >= threshold
+}
+
+// chunkDeviation returns the standard deviation of the chunks per shard and
+// its coefficient of variation, nil without chunks
+func chunkDeviation(shards []ShardChunks) (float64, *float64) {
+	if len(shards) == 0 {
+		return 0, nil
+	}
+	total := 0
+	for _, s := range shards {
+		total += s.Chunks
+	}
+	mean := float64(total) / float64(len(shards))
+	variance := 0.0
+	for _, s := range shards {
+		variance += (float64(s.Chunks) - mean) * (float64(s.Chunks) - mean)
+	}
+	stddev := math.Sqrt(variance / float64(len(shards)))
+	if total == 0 {
+		return stddev, nil
+	}
+	cv := stddev / mean
+	return stddev, &cv
+}
+
+// dataToMovePercent is the share of the data of a collection the balancer
+// has to move, nil without data
+func dataToMovePercent(remainChunksSize *int64, allDataSize int64) *float64 {
+	if remainChunksSize == nil || allDataSize <= 0 {
+		return nil
+	}
+	percent := float64(*remainChunksSize) / float64(allDataSize) * 100
+	return &percent
+}
+
+// imbalanceScore is the percent of data to move of the collections weighted
+// by their data size, nil without data
+func imbalanceScore(collections []CollectionStatus) *float64 {
+	var remain, total int64
+	for _, c := range collections {
+		if c.Error != "" || c.RemainChunksSize == nil {
+			continue
+		}
+		remain += *c.RemainChunksSize
+		total += c.AllDataSize
+	}
+	return dataToMovePercent(&remain, total)
+}
+
+// sortByImbalance puts the collections with the most data to move first, then
+// the most uneven chunk counts, keeping the collections grouped by database
+func sortByImbalance(collections []CollectionStatus) {
+	less := func(a *float64, b *float64) bool {
+		if a == nil || b == nil {
+			return a != nil
+		}
+		return *a > *b
+	}
+	sort.SliceStable(collections, func(i int, j int) bool {
+		a, b := collections[i], collections[j]
+		if a.Database != b.Database {
+			return a.Database < b.Database
+		}
+		if less(a.DataToMove, b.DataToMove) || less(b.DataToMove, a.DataToMove) {
+			return less(a.DataToMove, b.DataToMove)
+		}
+		return less(a.ChunksCV, b.ChunksCV)
+	})
+}
+
+// dataSkewThreshold is the share of the data a shard may hold beyond its share
+// of the chunks before the chunk counts no longer tell the data distribution
+const dataSkewThreshold = 0.25
+
+// checkDataSkew returns the largest excess of a shard's share of the data over
+// its share of the chunks, nil without data or chunks
+func checkDataSkew(shards []ShardChunks, chunks int, dataSize int64) *float64 {
+	if chunks == 0 || dataSize <= 0 {
+		return nil
+	}
+	skew := 0.0
+	for _, s := range shards {
+		excess := float64(s.DataSize)/float64(dataSize) - float64(s.Chunks)/float64(chunks)
+		if excess > skew {
+			skew = excess
+		}
+	}
+	return &skew
+}
+
+// nearJumboRatio is the average chunk size to max chunk size ratio from which
+// chunks risk growing past the max chunk size and becoming jumbo
+const nearJumboRatio = 0.8
+
+// checkChunkSize compares the average chunk size with the configured max chunk
+// size. Without the size of each chunk every chunk is assumed to exceed it when
+// the average does, and jumbo chunks are known to exceed it.
+func checkChunkSize(aveChunkSize *int64, chunks int, jumbos int, maxChunkSize int64) (*int, *float64, string) {
+	if aveChunkSize == nil || maxChunkSize <= 0 {
+		return nil, nil, ""
+	}
+	ratio := float64(*aveChunkSize) / float64(maxChunkSize)
+	oversized := jumbos
+	warning := ""
+	switch {
+	case ratio > 1:
+		oversized = chunks
+		warning = "oversized"
+	case ratio >= nearJumboRatio:
+		warning = "near-jumbo"
+	case jumbos > 0:
+		warning = "jumbo"
+	}
+	return &oversized, &ratio, warning
+}
+
+// DatabaseSummary is the subtotal of the collections of a database
+type DatabaseSummary struct {
+	Database         string `json:"database"`
+	Collections      int    `json:"collections"`
+	Objects          int    `json:"objects"`
+	Chunks           int    `json:"chunks"`
+	AllDataSize      int64  `json:"allDataSize"`
+	RemainChunks     int    `json:"remainChunks"`
+	RemainChunksSize int64  `json:"remainChunksSize"`
+	JumboChunks      int    `json:"jumboChunks"`
+}
+
+// Report is everything rendered by a status run
+type Report struct {
+	Cluster         ClusterStatus      `json:"cluster"`
+	Collections     []CollectionStatus `json:"collections"`
+	Databases       []DatabaseSummary  `json:"databases"`
+	Shards          []string           `json:"shards"`
+	ImbalanceScore  *float64           `json:"imbalanceScore"`
+	MultiDatabases  bool               `json:"-"`
+	PerShard        bool               `json:"-"`
+	ExactChunkSizes bool               `json:"-"`
+	Metadata        bool               `json:"-"`
+	Storage         bool               `json:"-"`
+	Thresholds      bool               `json:"-"`
+	Imbalance       bool               `json:"-"`
+	SampledAt       time.Time          `json:"sampledAt"`
+}
+
+// summarizeDatabases expects collections to be sorted by name
+func summarizeDatabases(collections []CollectionStatus) []DatabaseSummary {
+	summaries := []DatabaseSummary{}
+	for _, c := range collections {
+		if len(summaries) == 0 || summaries[len(summaries)-1].Database != c.Database {
+			summaries = append(summaries, DatabaseSummary{Database: c.Database})
+		}
+		s := &summaries[len(summaries)-1]
+		s.Collections++
+		s.Objects += c.Objects
+		s.Chunks += c.Chunks
+		s.AllDataSize += c.AllDataSize
+		s.RemainChunks += c.RemainChunks
+		if c.RemainChunksSize != nil {
+			s.RemainChunksSize += *c.RemainChunksSize
+		}
+		s.JumboChunks += c.JumboChunks
+	}
+	return summaries
+}
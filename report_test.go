@@ -0,0 +1,140 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func float(v float64) *float64 {
+	return &v
+}
+
+func size(v int64) *int64 {
+	return &v
+}
+
+func TestShardSpread(t *testing.T) {
+	shards := []ShardChunks{
+		{Shard: "s1", Chunks: 10, DataSize: 100},
+		{Shard: "s2", Chunks: 2, DataSize: 700},
+		{Shard: "s3", Chunks: 6, DataSize: 50},
+	}
+	minChunks, maxChunks, dataSpread := shardSpread(shards)
+	if minChunks != 2 || maxChunks != 10 || dataSpread != 650 {
+		t.Errorf("shardSpread = %d, %d, %d, want 2, 10, 650", minChunks, maxChunks, dataSpread)
+	}
+	if minChunks, maxChunks, dataSpread := shardSpread(nil); minChunks != 0 || maxChunks != 0 || dataSpread != 0 {
+		t.Errorf("shardSpread(nil) = %d, %d, %d, want zeros", minChunks, maxChunks, dataSpread)
+	}
+}
+
+func TestCheckMigrationThreshold(t *testing.T) {
+	const chunkSize = 128 * 1024 * 1024
+	tests := []struct {
+		chunkSpread   int
+		dataSpread    int64
+		chunks        int
+		model         string
+		wantThreshold int64
+		wantExceeded  bool
+	}{
+		{1, 0, 19, balanceModelChunks, 2, false},
+		{2, 0, 19, balanceModelChunks, 2, true},
+		{3, 0, 20, balanceModelChunks, 4, false},
+		{4, 0, 79, balanceModelChunks, 4, true},
+		{7, 0, 80, balanceModelChunks, 8, false},
+		{8, 0, 1000, balanceModelChunks, 8, true},
+		{100, 3*chunkSize - 1, 1000, balanceModelBytes, 3 * chunkSize, false},
+		{0, 3 * chunkSize, 3, balanceModelBytes, 3 * chunkSize, true},
+	}
+	for _, tt := range tests {
+		threshold, exceeded := checkMigrationThreshold(tt.chunkSpread, tt.dataSpread, tt.chunks, tt.model, chunkSize)
+		if threshold != tt.wantThreshold || exceeded != tt.wantExceeded {
+			t.Errorf("checkMigrationThreshold(%d, %d, %d, %s) = %d, %v, want %d, %v",
+				tt.chunkSpread, tt.dataSpread, tt.chunks, tt.model, threshold, exceeded, tt.wantThreshold, tt.wantExceeded)
+		}
+	}
+}
+
+func TestChunkDeviation(t *testing.T) {
+	stddev, cv := chunkDeviation([]ShardChunks{{Chunks: 2}, {Chunks: 4}, {Chunks: 4}, {Chunks: 4}, {Chunks: 5}, {Chunks: 5}, {Chunks: 7}, {Chunks: 9}})
+	if stddev != 2 || cv == nil || *cv != 0.4 {
+		t.Errorf("chunkDeviation = %v, %v, want 2, 0.4", stddev, cv)
+	}
+	if stddev, cv := chunkDeviation([]ShardChunks{{}, {}}); stddev != 0 || cv != nil {
+		t.Errorf("chunkDeviation without chunks = %v, %v, want 0, nil", stddev, cv)
+	}
+}
+
+func TestDataToMigrate(t *testing.T) {
+	ideal, remain := dataToMigrate([]ShardChunks{{DataSize: 600}, {DataSize: 200}, {DataSize: 100}})
+	if ideal != 300 || remain != 300 {
+		t.Errorf("dataToMigrate = %d, %d, want 300, 300", ideal, remain)
+	}
+}
+
+func TestEstimateChunkSizes(t *testing.T) {
+	shards := []ShardChunks{{Shard: "s1", Chunks: 2, DataSize: 300}, {Shard: "s2", Chunks: 0}}
+	chunks := ChunkSlice{{Shard: "s1"}, {Shard: "s2"}, {Shard: "s1"}}
+	got := estimateChunkSizes(chunks, shards)
+	if want := []int64{150, 0, 150}; !reflect.DeepEqual(got, want) {
+		t.Errorf("estimateChunkSizes = %v, want %v", got, want)
+	}
+}
+
+func TestDataToMovePercent(t *testing.T) {
+	tests := []struct {
+		remain *int64
+		all    int64
+		want   *float64
+	}{
+		{size(25), 100, float(25)},
+		{size(0), 100, float(0)},
+		{nil, 100, nil},
+		{size(25), 0, nil},
+	}
+	for _, tt := range tests {
+		got := dataToMovePercent(tt.remain, tt.all)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("dataToMovePercent(%v, %d) = %v, want %v", tt.remain, tt.all, got, tt.want)
+		}
+	}
+}
+
+func TestImbalanceScore(t *testing.T) {
+	collections := []CollectionStatus{
+		{Name: "a", AllDataSize: 900, RemainChunksSize: size(90)},
+		{Name: "b", AllDataSize: 100, RemainChunksSize: size(50)},
+		// failed and empty collections do not weigh in
+		{Name: "c", AllDataSize: 1000, RemainChunksSize: size(1000), Error: "failed"},
+		{Name: "d", AllDataSize: 0},
+	}
+	score := imbalanceScore(collections)
+	if score == nil || math.Abs(*score-14) > 1e-9 {
+		t.Errorf("imbalanceScore = %v, want 14", score)
+	}
+	if score := imbalanceScore(nil); score != nil {
+		t.Errorf("imbalanceScore(nil) = %v, want nil", *score)
+	}
+}
+
+func TestSortByImbalance(t *testing.T) {
+	collections := []CollectionStatus{
+		{Database: "b", Name: "b.x", DataToMove: float(99)},
+		{Database: "a", Name: "a.low", DataToMove: float(5)},
+		{Database: "a", Name: "a.nodata"},
+		{Database: "a", Name: "a.high", DataToMove: float(50)},
+		{Database: "a", Name: "a.tie-even", DataToMove: float(5), ChunksCV: float(0.1)},
+		{Database: "a", Name: "a.tie-uneven", DataToMove: float(5), ChunksCV: float(0.9)},
+	}
+	sortByImbalance(collections)
+	var got []string
+	for _, c := range collections {
+		got = append(got, c.Name)
+	}
+	want := []string{"a.high", "a.tie-uneven", "a.tie-even", "a.low", "a.nodata", "b.x"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("sortByImbalance = %v, want %v", got, want)
+	}
+}
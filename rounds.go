@@ -0,0 +1,120 @@
+package main
+
+import (
+	"io"
+	"strconv"
+	"time"
+
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// ActionlogEntry is mongo config.actionlog balancer.round document
+type ActionlogEntry struct {
+	Server  string    `bson:"server"`
+	Time    time.Time `bson:"time"`
+	Details struct {
+		ExecutionTimeMillis int64  `bson:"executionTimeMillis"`
+		ErrorOccured        bool   `bson:"errorOccured"`
+		CandidateChunks     int    `bson:"candidateChunks"`
+		ChunksMoved         int    `bson:"chunksMoved"`
+		Errmsg              string `bson:"errmsg"`
+	} `bson:"details"`
+}
+
+// BalancerRound is a balancer round, Duration is in milliseconds
+type BalancerRound struct {
+	Time            time.Time `json:"time"`
+	Server          string    `json:"server"`
+	Duration        int64     `json:"duration"`
+	CandidateChunks int       `json:"candidateChunks"`
+	ChunksMoved     int       `json:"chunksMoved"`
+	Error           string    `json:"error,omitempty"`
+}
+
+// BalancerRounds is the recent rounds newest first and what they tell about
+// the balancer
+type BalancerRounds struct {
+	Diagnosis string          `json:"diagnosis"`
+	Rounds    []BalancerRound `json:"rounds"`
+}
+
+var roundsHeader = []string{
+	"time",
+	"server",
+	"duration(ms)",
+	"candidateChunks",
+	"chunksMoved",
+	"error",
+}
+
+// getBalancerRounds reads the limit latest rounds of config.actionlog
+func getBalancerRounds(db *mgo.Database, limit int) (BalancerRounds, error) {
+	var entries []ActionlogEntry
+	err := db.C("actionlog").Find(bson.M{"what": "balancer.round"}).Sort("-time").Limit(limit).All(&entries)
+	if err != nil {
+		return BalancerRounds{}, err
+	}
+	rounds := make([]BalancerRound, len(entries))
+	for i, e := range entries {
+		rounds[i] = BalancerRound{
+			Time:            e.Time,
+			Server:          e.Server,
+			Duration:        e.Details.ExecutionTimeMillis,
+			CandidateChunks: e.Details.CandidateChunks,
+			ChunksMoved:     e.Details.ChunksMoved,
+			Error:           e.Details.Errmsg,
+		}
+		if e.Details.ErrorOccured && rounds[i].Error == "" {
+			rounds[i].Error = "error occurred"
+		}
+	}
+	return BalancerRounds{Diagnosis: diagnoseRounds(rounds), Rounds: rounds}, nil
+}
+
+// diagnoseRounds tells from the rounds newest first whether the balancer is
+// erroring, stuck with candidates it does not move, idle or balancing
+func diagnoseRounds(rounds []BalancerRound) string {
+	if len(rounds) == 0 {
+		return "no rounds"
+	}
+	if rounds[0].Error != "" {
+		return "erroring"
+	}
+	candidates, moved := 0, 0
+	for _, r := range rounds {
+		candidates += r.CandidateChunks
+		moved += r.ChunksMoved
+	}
+	switch {
+	case candidates == 0:
+		return "idle"
+	case moved == 0:
+		return "stuck"
+	}
+	return "balancing"
+}
+
+func renderBalancerRounds(w io.Writer, format string, rounds BalancerRounds) error {
+	v := view{
+		header:   roundsHeader,
+		footer:   "balancer: " + rounds.Diagnosis,
+		document: rounds,
+	}
+	for _, r := range rounds.Rounds {
+		v.rows = append(v.rows, r.row())
+		v.records = append(v.records, r)
+	}
+	return renderView(w, format, v)
+}
+
+func (r BalancerRound) row() []string {
+	return []string{
+		r.Time.Format(time.RFC3339),
+		r.Server,
+		strconv.FormatInt(r.Duration, 10),
+		strconv.Itoa(r.CandidateChunks),
+		strconv.Itoa(r.ChunksMoved),
+		r.Error,
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/codegangsta/cli"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// ShardSummary is a shard of the inventory with its totals over every
+// sharded collection, DataSize sums the collStats breakdown by shard
+type ShardSummary struct {
+	Shard            string   `json:"shard"`
+	Host             string   `json:"host"`
+	State            int      `json:"state"`
+	Draining         bool     `json:"draining"`
+	Tags             []string `json:"tags"`
+	MaxSize          int64    `json:"maxSize"`
+	Chunks           int      `json:"chunks"`
+	PrimaryDatabases int      `json:"primaryDatabases"`
+	DataSize         int64    `json:"dataSize"`
+}
+
+var shardsHeader = []string{
+	"shard",
+	"host",
+	"state",
+	"draining",
+	"tags",
+	"maxSize(MB)",
+	"chunks",
+	"primaryDbs",
+	"dataSize(MB)",
+}
+
+func shardsCommand(global *GlobalOptions) cli.Command {
+	return cli.Command{
+		Name:  "shards",
+		Usage: "show every shard with its state, tags and totals over all sharded collections",
+		Action: func(c *cli.Context) error {
+			format, err := global.outputFormat()
+			if err != nil {
+				return err
+			}
+			session, err := global.connect()
+			if err != nil {
+				return err
+			}
+			defer session.Close()
+
+			// the totals are over every database whatever --db says, a
+			// collection without collStats only misses from the data size
+			shards, unsized, err := getShardSummaries(session)
+			if err != nil {
+				return cli.NewExitError(err.Error(), exitFatal)
+			}
+			if err := renderShards(os.Stdout, format, shards); err != nil {
+				return cli.NewExitError(err.Error(), exitFatal)
+			}
+			if len(unsized) == 0 {
+				return nil
+			}
+			namespaces := make([]string, 0, len(unsized))
+			for ns := range unsized {
+				namespaces = append(namespaces, ns)
+			}
+			sort.Strings(namespaces)
+			for _, ns := range namespaces {
+				fmt.Fprintf(os.Stderr, "data size of %s not counted: %v\n", ns, unsized[ns])
+			}
+			return cli.NewExitError("", exitPartial)
+		},
+	}
+}
+
+// getShardSummaries counts the chunks of every collection per shard on the
+// config servers and sums their collStats by shard, the collections
+// collStats failed on are returned with their error
+func getShardSummaries(session *mgo.Session) ([]ShardSummary, map[string]error, error) {
+	configDb := session.DB("config")
+	cfShards, err := getShards(configDb)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot read config.shards: %v", err)
+	}
+	var cfDatabases []Database
+	err = configDb.C("databases").Find(bson.M{}).All(&cfDatabases)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot read config.databases: %v", err)
+	}
+	databases, err := getDatabases(configDb, nil, true)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot read config.databases: %v", err)
+	}
+	cfCollections, err := getCollections(configDb, databases, false)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot read config.collections: %v", err)
+	}
+	chunkCounts, err := getShardChunkCounts(configDb)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot read config.chunks: %v", err)
+	}
+
+	shards := make([]ShardSummary, len(cfShards))
+	index := make(map[string]int)
+	for i, s := range cfShards {
+		index[s.ID] = i
+		shards[i] = ShardSummary{
+			Shard:    s.ID,
+			Host:     s.Host,
+			State:    s.State,
+			Draining: s.Draining,
+			Tags:     s.Tags,
+			MaxSize:  s.MaxSize * 1024 * 1024,
+		}
+	}
+	for _, d := range cfDatabases {
+		if i, ok := index[d.Primary]; ok {
+			shards[i].PrimaryDatabases++
+		}
+	}
+	for shard, count := range chunkCounts {
+		if i, ok := index[shard]; ok {
+			shards[i].Chunks = count
+		}
+	}
+
+	unsized := make(map[string]error)
+	for _, collection := range cfCollections {
+		dbName, collectionName := splitNamespace(collection.ID)
+		colstats, err := getCollStats(session.DB(dbName), collectionName)
+		if err != nil {
+			unsized[collection.ID] = err
+			continue
+		}
+		for shard, stats := range colstats.Shards {
+			if i, ok := index[shard]; ok {
+				shards[i].DataSize += stats.Size
+			}
+		}
+	}
+	return shards, unsized, nil
+}
+
+func renderShards(w io.Writer, format string, shards []ShardSummary) error {
+	v := view{
+		header:    shardsHeader,
+		csvHeader: append([]string{}, shardsHeader...),
+		document: struct {
+			Shards []ShardSummary `json:"shards"`
+		}{shards},
+	}
+	v.csvHeader[5] = "maxSize(B)"
+	v.csvHeader[8] = "dataSize(B)"
+	for _, s := range shards {
+		v.rows = append(v.rows, s.row(formatMB))
+		v.csvRows = append(v.csvRows, s.row(formatBytes))
+		v.records = append(v.records, s)
+	}
+	return renderView(w, format, v)
+}
+
+// row leaves maxSize empty when it is unlimited
+func (s ShardSummary) row(formatSize func(int64) string) []string {
+	maxSize := ""
+	if s.MaxSize > 0 {
+		maxSize = formatSize(s.MaxSize)
+	}
+	return []string{
+		s.Shard,
+		s.Host,
+		strconv.Itoa(s.State),
+		formatBool(s.Draining),
+		strings.Join(s.Tags, ","),
+		maxSize,
+		strconv.Itoa(s.Chunks),
+		strconv.Itoa(s.PrimaryDatabases),
+		formatSize(s.DataSize),
+	}
+}
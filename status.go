@@ -0,0 +1,358 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	mgo "gopkg.in/mgo.v2"
+)
+
+// StatusOptions is the set of flags of the collection status report
+type StatusOptions struct {
+	DatabasePatterns []string
+	AllDatabases     bool
+	PerShard         bool
+	ExactChunkSizes  bool
+	BalanceModel     string
+	Metadata         bool
+	Storage          bool
+	Thresholds       bool
+	Imbalance        bool
+	SortByImbalance  bool
+	IncludeDropped   bool
+	Concurrency      int
+	Timezone         *time.Location
+	Format           string
+}
+
+// getReport reads the config database and collStats of every selected
+// collection, a collStats failure is kept in the collection's Error
+func getReport(session *mgo.Session, opts StatusOptions) (Report, error) {
+	configDb := session.DB("config")
+
+	// get config status
+	cluster, err := getClusterStatus(session, opts.Timezone)
+	if err != nil {
+		return Report{}, err
+	}
+	databases, err := getDatabases(configDb, opts.DatabasePatterns, opts.AllDatabases)
+	if err != nil {
+		return Report{}, fmt.Errorf("cannot read config.databases: %v", err)
+	}
+	cfShards, err := getShards(configDb)
+	if err != nil {
+		return Report{}, fmt.Errorf("cannot read config.shards: %v", err)
+	}
+	cfCollections, err := getCollections(configDb, databases, opts.IncludeDropped)
+	if err != nil {
+		return Report{}, fmt.Errorf("cannot read config.collections: %v", err)
+	}
+	byUUID, err := chunksKeyedByUUID(configDb)
+	if err != nil {
+		return Report{}, fmt.Errorf("cannot read config.chunks: %v", err)
+	}
+	cfChunkCounts, err := getChunkCounts(configDb, cfCollections, byUUID)
+	if err != nil {
+		return Report{}, fmt.Errorf("cannot read config.chunks: %v", err)
+	}
+	zoneRangesByNs, err := getZoneRanges(configDb, cfCollections)
+	if err != nil {
+		return Report{}, fmt.Errorf("cannot read config.tags: %v", err)
+	}
+	balanceModel := opts.BalanceModel
+	if balanceModel == "" || balanceModel == balanceModelAuto {
+		balanceModel = cluster.BalanceModel
+	}
+	shardsNum := len(cfShards)
+	collectionsNum := len(cfCollections)
+
+	// collections sort by name
+	sort.Slice(cfCollections, func(i int, j int) bool {
+		return cfCollections[i].ID < cfCollections[j].ID
+	})
+
+	chunkCountsByNs := make(map[string]ChunkCountSlice)
+	for _, count := range cfChunkCounts {
+		chunkCountsByNs[count.Ns] = append(chunkCountsByNs[count.Ns], count)
+	}
+
+	concurrency := opts.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+
+	// create collection info
+	var wg sync.WaitGroup
+	collections := make([]CollectionStatus, collectionsNum)
+	for i := 0; i < collectionsNum; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			collectionName := cfCollections[i].ID
+			dbName, collectionNameWithoutDb := splitNamespace(collectionName)
+			selectDb := session.DB(dbName)
+
+			// get data
+			chunks := chunkCountsByNs[collectionName]
+			chunksNum := chunks.Sum()
+			// a failed collStats leaves the sizes at zero and marks the row
+			colstats, err := getCollStats(selectDb, collectionNameWithoutDb)
+			errorMessage := ""
+			if err != nil {
+				errorMessage = err.Error()
+			}
+			objsNum := colstats.Count
+			jumboChunksNum := chunks.Where(func(arg1 ChunkCount) bool {
+				return arg1.Jumbo == true
+			}).Sum()
+
+			// chunk sizes are n/a without chunks or documents
+			var aveChunkSize *int64
+			if chunksNum > 0 && objsNum > 0 {
+				size := colstats.Size / int64(chunksNum)
+				aveChunkSize = &size
+			}
+
+			// check ideal per shard
+			idealChunksPerShardsNum := idealChunksPerShard(chunksNum, shardsNum)
+
+			// get remain chunks data
+			remainChunksNum := 0
+			shards := make([]ShardChunks, shardsNum)
+			for j := 0; j < shardsNum; j++ {
+				shardChunksNum := chunks.Where(func(arg1 ChunkCount) bool {
+					return arg1.Shard == cfShards[j].ID
+				}).Sum()
+				if shardChunksNum > idealChunksPerShardsNum {
+					remainChunksNum += (shardChunksNum - idealChunksPerShardsNum)
+				}
+				shards[j] = ShardChunks{
+					Shard:  cfShards[j].ID,
+					Chunks: shardChunksNum,
+				}
+				// mongos breaks collStats down by shard, older ones did not
+				if stats, ok := colstats.Shards[cfShards[j].ID]; ok {
+					objects := stats.Count
+					shards[j].Objects = &objects
+					shards[j].DataSize = stats.Size
+				} else if aveChunkSize != nil {
+					shards[j].DataSize = *aveChunkSize * int64(shardChunksNum)
+				}
+			}
+			var dataSkew *float64
+			if len(colstats.Shards) > 0 {
+				dataSkew = checkDataSkew(shards, chunksNum, colstats.Size)
+			}
+
+			// zones restrict where chunks may live, the remaining chunks
+			// then come from the distribution within each zone
+			var zones []ZoneChunks
+			var zoneChunks ChunkSlice
+			zoneViolationsNum := 0
+			ranges := zoneRangesByNs[collectionName]
+			if len(ranges) > 0 && errorMessage == "" {
+				zoneChunks, err = getCollectionChunks(configDb, cfCollections[i], byUUID)
+				if err != nil {
+					errorMessage = err.Error()
+				} else {
+					zones = summarizeZones(zoneChunks, ranges, cfShards)
+					remainChunksNum = 0
+					for _, z := range zones {
+						remainChunksNum += z.RemainChunks
+						zoneViolationsNum += z.Violations
+					}
+				}
+			}
+			var remainChunksSize *int64
+			if aveChunkSize != nil {
+				size := *aveChunkSize * int64(remainChunksNum)
+				remainChunksSize = &size
+			}
+
+			shardKey, err := marshalBounds(cfCollections[i].Key)
+			if err != nil && errorMessage == "" {
+				errorMessage = err.Error()
+			}
+			var defaultCollation json.RawMessage
+			if len(cfCollections[i].DefaultCollation) > 0 {
+				defaultCollation, err = marshalBounds(cfCollections[i].DefaultCollation)
+				if err != nil && errorMessage == "" {
+					errorMessage = err.Error()
+				}
+			}
+			// the uncompressed data size over the size on disk
+			var compressionRatio *float64
+			if colstats.StorageSize > 0 {
+				ratio := float64(colstats.Size) / float64(colstats.StorageSize)
+				compressionRatio = &ratio
+			}
+			lastmodEpoch := ""
+			if cfCollections[i].LastmodEpoch.Valid() {
+				lastmodEpoch = cfCollections[i].LastmodEpoch.Hex()
+			}
+
+			oversizedChunksNum, chunkSizeRatio, warning := checkChunkSize(aveChunkSize, chunksNum, jumboChunksNum, cluster.ChunkSize)
+
+			if zoneViolationsNum > 0 && warning == "" {
+				warning = "zone-violation"
+			}
+			if dataSkew != nil && *dataSkew >= dataSkewThreshold && warning == "" {
+				warning = "data-skew"
+			}
+
+			// exact sizes replace the estimates from the average chunk size
+			var chunkSizes *ChunkSizes
+			var exactSizes []chunkDataSize
+			if opts.ExactChunkSizes && errorMessage == "" {
+				// the chunks read for the zones are measured in the same order
+				var sizes []chunkDataSize
+				if zones != nil {
+					sizes, err = getChunkDataSizes(session, cfCollections[i], zoneChunks, sem)
+				} else {
+					sizes, err = getExactChunkSizes(session, cfCollections[i], byUUID, sem)
+				}
+				if err != nil {
+					errorMessage = err.Error()
+				} else {
+					exactSizes = sizes
+					distribution, shardSizes := summarizeChunkSizes(sizes, cluster.ChunkSize)
+					chunkSizes = &distribution
+					oversizedChunksNum = &distribution.Oversized
+					if distribution.Oversized > 0 && warning == "" {
+						warning = "oversized"
+					}
+					for j := range shards {
+						shards[j].DataSize = shardSizes[shards[j].Shard]
+					}
+				}
+			}
+
+			// balancing by data size moves what exceeds the average data per
+			// shard, after the exact sizes replaced the estimates. Zones
+			// balance the data within each zone, which has no single ideal.
+			var idealDataPerShard *int64
+			if balanceModel == balanceModelBytes && aveChunkSize != nil && *aveChunkSize > 0 {
+				var remain int64
+				if zones != nil {
+					sizes := estimateChunkSizes(zoneChunks, shards)
+					if exactSizes != nil {
+						for j := range exactSizes {
+							sizes[j] = exactSizes[j].Size
+						}
+					}
+					remain = zoneDataToMigrate(zoneChunks, sizes, ranges, cfShards)
+				} else {
+					ideal, shardsRemain := dataToMigrate(shards)
+					idealDataPerShard = &ideal
+					remain = shardsRemain
+				}
+				remainChunksSize = &remain
+				remainChunksNum = int(math.Ceil(float64(remain) / float64(*aveChunkSize)))
+			}
+
+			// the balancer leaves the collection alone below the threshold
+			minShardChunks, maxShardChunks, dataSpread := shardSpread(shards)
+			chunkSpread := maxShardChunks - minShardChunks
+			migrationThreshold, exceeded := checkMigrationThreshold(chunkSpread, dataSpread, chunksNum, balanceModel, cluster.ChunkSize)
+			balancerActs := exceeded && cluster.BalancerEnabled && !cfCollections[i].NoBalance
+			chunksStdDev, chunksCV := chunkDeviation(shards)
+
+			collections[i] = CollectionStatus{
+				Database:            dbName,
+				Name:                collectionName,
+				Objects:             objsNum,
+				Chunks:              chunksNum,
+				AveChunkSize:        aveChunkSize,
+				AllDataSize:         colstats.Size,
+				BalanceModel:        balanceModel,
+				IdealChunksPerShard: idealChunksPerShardsNum,
+				IdealDataPerShard:   idealDataPerShard,
+				RemainChunks:        remainChunksNum,
+				RemainChunksSize:    remainChunksSize,
+				JumboChunks:         jumboChunksNum,
+				OversizedChunks:     oversizedChunksNum,
+				ChunkSizeRatio:      chunkSizeRatio,
+				Warning:             warning,
+				Balancer:            !cfCollections[i].NoBalance,
+				ChunkSpread:         chunkSpread,
+				DataSpread:          dataSpread,
+				MigrationThreshold:  migrationThreshold,
+				BalancerActs:        balancerActs,
+				MinShardChunks:      minShardChunks,
+				MaxShardChunks:      maxShardChunks,
+				ChunksStdDev:        chunksStdDev,
+				ChunksCV:            chunksCV,
+				DataToMove:          dataToMovePercent(remainChunksSize, colstats.Size),
+				StorageSize:         colstats.StorageSize,
+				IndexSize:           colstats.TotalIndexSize,
+				Indexes:             colstats.Nindexes,
+				IndexSizes:          colstats.IndexSizes,
+				CompressionRatio:    compressionRatio,
+				ShardKey:            shardKey,
+				Unique:              cfCollections[i].Unique,
+				Dropped:             cfCollections[i].Dropped,
+				LastmodEpoch:        lastmodEpoch,
+				DefaultCollation:    defaultCollation,
+				Shards:              shards,
+				DataSkew:            dataSkew,
+				Zones:               zones,
+				ZoneViolations:      zoneViolationsNum,
+				ChunkSizes:          chunkSizes,
+				Error:               errorMessage,
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if opts.SortByImbalance {
+		sortByImbalance(collections)
+	}
+
+	shardNames := make([]string, shardsNum)
+	for j := 0; j < shardsNum; j++ {
+		shardNames[j] = cfShards[j].ID
+	}
+
+	report := Report{
+		Cluster:         cluster,
+		Collections:     collections,
+		Databases:       summarizeDatabases(collections),
+		Shards:          shardNames,
+		ImbalanceScore:  imbalanceScore(collections),
+		MultiDatabases:  opts.AllDatabases || databases.Cardinality() > 1,
+		PerShard:        opts.PerShard,
+		ExactChunkSizes: opts.ExactChunkSizes,
+		Metadata:        opts.Metadata,
+		Storage:         opts.Storage,
+		Thresholds:      opts.Thresholds,
+		Imbalance:       opts.Imbalance,
+		SampledAt:       time.Now(),
+	}
+	return report, nil
+}
+
+// reportFailures summarises failed collections on stderr so stdout stays
+// parseable, and returns how many failed
+func reportFailures(report Report) int {
+	failed := 0
+	for _, collection := range report.Collections {
+		if collection.Error != "" {
+			failed++
+		}
+	}
+	if failed > 0 {
+		fmt.Fprintf(os.Stderr, "%d of %d collections failed:\n", failed, len(report.Collections))
+		for _, collection := range report.Collections {
+			if collection.Error != "" {
+				fmt.Fprintf(os.Stderr, "  %s: %s\n", collection.Name, collection.Error)
+			}
+		}
+	}
+	return failed
+}
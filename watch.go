@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	mgo "gopkg.in/mgo.v2"
+)
+
+// clearScreen moves the cursor home and clears the terminal
+const clearScreen = "\033[H\033[2J"
+
+// CollectionDelta is the progress of a collection in watch mode, the rate and
+// the estimated time to balanced are observed since the first sample
+type CollectionDelta struct {
+	MovedChunks        int      `json:"movedChunks"`
+	RemainChunksChange int      `json:"remainChunksChange"`
+	ChunksPerMinute    float64  `json:"chunksPerMinute"`
+	ETASeconds         *float64 `json:"etaSeconds"`
+}
+
+// watchStatus renders the report every interval until interrupted
+func watchStatus(session *mgo.Session, opts StatusOptions, interval time.Duration) error {
+	redraw := opts.Format == formatTable || opts.Format == formatMarkdown
+	var first, previous *Report
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		// keep watching through failures, the cluster may be busy balancing
+		report, err := getReport(session, opts)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		} else {
+			if first == nil {
+				first = &report
+			} else {
+				setDeltas(&report, *previous, *first)
+			}
+
+			if redraw {
+				fmt.Print(clearScreen)
+			}
+			if err := render(os.Stdout, opts.Format, report); err != nil {
+				return err
+			}
+			if redraw {
+				renderDeltas(os.Stdout, opts.Format == formatMarkdown, report, interval)
+			}
+			reportFailures(report)
+			previous = &report
+		}
+		<-ticker.C
+	}
+}
+
+// setDeltas compares report with the previous and the first sample
+func setDeltas(report *Report, previous Report, first Report) {
+	previousByName := make(map[string]CollectionStatus)
+	for _, c := range previous.Collections {
+		previousByName[c.Name] = c
+	}
+	firstByName := make(map[string]CollectionStatus)
+	for _, c := range first.Collections {
+		firstByName[c.Name] = c
+	}
+	elapsed := report.SampledAt.Sub(first.SampledAt).Minutes()
+
+	for i := range report.Collections {
+		c := &report.Collections[i]
+		prev, ok := previousByName[c.Name]
+		if !ok {
+			continue
+		}
+		delta := CollectionDelta{
+			MovedChunks:        movedChunks(prev.Shards, c.Shards),
+			RemainChunksChange: c.RemainChunks - prev.RemainChunks,
+		}
+		if start, ok := firstByName[c.Name]; ok && elapsed > 0 {
+			delta.ChunksPerMinute = float64(start.RemainChunks-c.RemainChunks) / elapsed
+		}
+		delta.ETASeconds = etaSeconds(c.RemainChunks, delta.ChunksPerMinute)
+		c.Delta = &delta
+	}
+}
+
+// movedChunks estimates migrations between two samples, a migration removes a
+// chunk from one shard and adds it to another while a split only adds one
+func movedChunks(before []ShardChunks, after []ShardChunks) int {
+	beforeByShard := make(map[string]int)
+	for _, s := range before {
+		beforeByShard[s.Shard] = s.Chunks
+	}
+	added, removed := 0, 0
+	for _, s := range after {
+		diff := s.Chunks - beforeByShard[s.Shard]
+		if diff > 0 {
+			added += diff
+		} else {
+			removed -= diff
+		}
+	}
+	if added < removed {
+		return added
+	}
+	return removed
+}
+
+// etaSeconds is nil when the remain chunks are not decreasing
+func etaSeconds(remainChunks int, chunksPerMinute float64) *float64 {
+	eta := 0.0
+	if remainChunks == 0 {
+		return &eta
+	}
+	if chunksPerMinute <= 0 {
+		return nil
+	}
+	eta = float64(remainChunks) / chunksPerMinute * 60
+	return &eta
+}
+
+func renderDeltas(w io.Writer, markdown bool, report Report, interval time.Duration) {
+	fmt.Fprintf(w, "\nsampled at %s every %s\n", report.SampledAt.Format("15:04:05"), interval)
+
+	table := newTable(w, markdown)
+	table.SetHeader([]string{
+		"CollectionName",
+		"movedChunks",
+		"remainChunksChange",
+		"chunksPerMinute",
+		"ETA",
+	})
+	rows, totalMoved, totalChange, totalRemain := 0, 0, 0, 0
+	totalRate := 0.0
+	for _, c := range report.Collections {
+		if c.Delta == nil {
+			continue
+		}
+		table.Append([]string{
+			c.Name,
+			strconv.Itoa(c.Delta.MovedChunks),
+			strconv.Itoa(c.Delta.RemainChunksChange),
+			strconv.FormatFloat(c.Delta.ChunksPerMinute, 'f', 2, 64),
+			formatETA(c.Delta.ETASeconds),
+		})
+		rows++
+		totalMoved += c.Delta.MovedChunks
+		totalChange += c.Delta.RemainChunksChange
+		totalRemain += c.RemainChunks
+		totalRate += c.Delta.ChunksPerMinute
+	}
+	if rows == 0 {
+		fmt.Fprintln(w, "waiting for the next sample")
+		return
+	}
+	table.Append([]string{
+		"total",
+		strconv.Itoa(totalMoved),
+		strconv.Itoa(totalChange),
+		strconv.FormatFloat(totalRate, 'f', 2, 64),
+		formatETA(etaSeconds(totalRemain, totalRate)),
+	})
+	table.Render()
+}
+
+func formatETA(seconds *float64) string {
+	if seconds == nil {
+		return "n/a"
+	}
+	return (time.Duration(*seconds) * time.Second).String()
+}
@@ -0,0 +1,429 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// ZoneRange is mongo config.tags document
+type ZoneRange struct {
+	Ns  string `bson:"ns"`
+	Min bson.D `bson:"min"`
+	Max bson.D `bson:"max"`
+	Tag string `bson:"tag"`
+}
+
+// ZoneChunks is the distribution of the chunks of a zoned collection within
+// a zone, Zone is empty for the chunks outside every zone range which may
+// live on any shard. Violations are chunks on a shard outside the zone.
+type ZoneChunks struct {
+	Zone                string   `json:"zone"`
+	Ranges              int      `json:"ranges"`
+	Shards              []string `json:"shards"`
+	Chunks              int      `json:"chunks"`
+	IdealChunksPerShard int      `json:"idealChunksPerShard"`
+	RemainChunks        int      `json:"remainChunks"`
+	Violations          int      `json:"violations"`
+}
+
+var zonesHeader = []string{
+	"CollectionName",
+	"zone",
+	"ranges",
+	"shards",
+	"chunks",
+	"idealChunksPerShards",
+	"remainChunks",
+	"violations",
+}
+
+// getZoneRanges reads the zone ranges of collections keyed by namespace
+func getZoneRanges(db *mgo.Database, collections CollectionSlice) (map[string][]ZoneRange, error) {
+	namespaces := make([]string, len(collections))
+	for i, c := range collections {
+		namespaces[i] = c.ID
+	}
+	var ranges []ZoneRange
+	err := db.C("tags").Find(bson.M{"ns": bson.M{"$in": namespaces}}).All(&ranges)
+	if err != nil {
+		return nil, err
+	}
+	byNs := make(map[string][]ZoneRange)
+	for _, r := range ranges {
+		byNs[r.Ns] = append(byNs[r.Ns], r)
+	}
+	return byNs, nil
+}
+
+// summarizeZones places every chunk in the zone range holding its min bound
+// and computes the ideal distribution over the shards of each zone
+func summarizeZones(chunks ChunkSlice, ranges []ZoneRange, shards ShardSlice) []ZoneChunks {
+	sort.Slice(ranges, func(i int, j int) bool {
+		return compareBounds(ranges[i].Min, ranges[j].Min) < 0
+	})
+
+	// zones in range order, the chunks outside every range last
+	var zones []ZoneChunks
+	index := make(map[string]int)
+	for _, r := range ranges {
+		if i, ok := index[r.Tag]; ok {
+			zones[i].Ranges++
+			continue
+		}
+		index[r.Tag] = len(zones)
+		zones = append(zones, ZoneChunks{Zone: r.Tag, Ranges: 1, Shards: []string{}})
+	}
+	for i := range zones {
+		for _, s := range shards {
+			if hasTag(s.Tags, zones[i].Zone) {
+				zones[i].Shards = append(zones[i].Shards, s.ID)
+			}
+		}
+	}
+	unzoned := ZoneChunks{Shards: make([]string, len(shards))}
+	for i, s := range shards {
+		unzoned.Shards[i] = s.ID
+	}
+	zones = append(zones, unzoned)
+	index[""] = len(zones) - 1
+
+	counts := make([]map[string]int, len(zones))
+	for i := range counts {
+		counts[i] = make(map[string]int)
+	}
+	for _, chunk := range chunks {
+		i := index[zoneOf(chunk, ranges)]
+		zones[i].Chunks++
+		counts[i][chunk.Shard]++
+	}
+
+	for i := range zones {
+		zone := &zones[i]
+		zone.IdealChunksPerShard = idealChunksPerShard(zone.Chunks, len(zone.Shards))
+		inZone := 0
+		for _, shard := range zone.Shards {
+			n := counts[i][shard]
+			inZone += n
+			if n > zone.IdealChunksPerShard {
+				zone.RemainChunks += n - zone.IdealChunksPerShard
+			}
+		}
+		zone.Violations = zone.Chunks - inZone
+		zone.RemainChunks += zone.Violations
+	}
+	if zones[len(zones)-1].Chunks == 0 {
+		zones = zones[:len(zones)-1]
+	}
+	return zones
+}
+
+// zoneOf is the zone of the range holding the min bound of chunk, empty
+// outside every range
+func zoneOf(chunk Chunk, ranges []ZoneRange) string {
+	for _, r := range ranges {
+		if compareBounds(r.Min, chunk.Min) <= 0 && compareBounds(chunk.Min, r.Max) < 0 {
+			return r.Tag
+		}
+	}
+	return ""
+}
+
+// zoneDataToMigrate is dataToMigrate within each zone, the data per shard
+// counts only the chunks of the zone and the chunks on shards outside their
+// zone move entirely. sizes holds the data size of every chunk.
+func zoneDataToMigrate(chunks ChunkSlice, sizes []int64, ranges []ZoneRange, shards ShardSlice) int64 {
+	byZone := make(map[string]map[string]int64)
+	for i, chunk := range chunks {
+		zone := zoneOf(chunk, ranges)
+		if byZone[zone] == nil {
+			byZone[zone] = make(map[string]int64)
+		}
+		byZone[zone][chunk.Shard] += sizes[i]
+	}
+
+	var remain int64
+	for zone, shardSizes := range byZone {
+		var inZone []ShardChunks
+		for _, s := range shards {
+			if zone == "" || hasTag(s.Tags, zone) {
+				inZone = append(inZone, ShardChunks{Shard: s.ID, DataSize: shardSizes[s.ID]})
+				delete(shardSizes, s.ID)
+			}
+		}
+		_, zoneRemain := dataToMigrate(inZone)
+		remain += zoneRemain
+		for _, size := range shardSizes {
+			remain += size
+		}
+	}
+	return remain
+}
+
+// idealChunksPerShard spreads chunks evenly, at least one per shard while
+// there are chunks and none when there are no shards to hold them
+func idealChunksPerShard(chunks int, shards int) int {
+	if chunks == 0 || shards == 0 {
+		return 0
+	}
+	if chunks > shards {
+		return int(math.Ceil(float64(chunks) / float64(shards)))
+	}
+	return 1
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// compareBounds compares shard key bounds field by field, both are expected
+// to follow the same key pattern
+func compareBounds(a bson.D, b bson.D) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := compareValues(a[i].Value, b[i].Value); c != 0 {
+			return c
+		}
+	}
+	return len(a) - len(b)
+}
+
+// compareValues follows the BSON comparison order of MongoDB for the types a
+// shard key may hold
+func compareValues(a interface{}, b interface{}) int {
+	ta, tb := typeOrder(a), typeOrder(b)
+	if ta != tb {
+		return ta - tb
+	}
+	switch va := a.(type) {
+	case int, int64, float64:
+		return compareNumbers(a, b)
+	case string, bson.Symbol:
+		return strings.Compare(toString(a), toString(b))
+	case bson.D:
+		if vb, ok := b.(bson.D); ok {
+			return compareBounds(va, vb)
+		}
+	case []interface{}:
+		vb := b.([]interface{})
+		for i := 0; i < len(va) && i < len(vb); i++ {
+			if c := compareValues(va[i], vb[i]); c != 0 {
+				return c
+			}
+		}
+		return len(va) - len(vb)
+	case bson.Binary, []byte:
+		return compareBinary(a, b)
+	case bson.ObjectId:
+		return strings.Compare(string(va), string(b.(bson.ObjectId)))
+	case bool:
+		vb := b.(bool)
+		if va == vb {
+			return 0
+		}
+		if vb {
+			return -1
+		}
+		return 1
+	case time.Time:
+		vb := b.(time.Time)
+		if va.Before(vb) {
+			return -1
+		}
+		if va.After(vb) {
+			return 1
+		}
+		return 0
+	case bson.MongoTimestamp:
+		vb := b.(bson.MongoTimestamp)
+		if uint64(va) < uint64(vb) {
+			return -1
+		}
+		if uint64(va) > uint64(vb) {
+			return 1
+		}
+		return 0
+	}
+	return 0
+}
+
+// typeOrder is the rank of the type of v in the BSON comparison order
+func typeOrder(v interface{}) int {
+	switch v {
+	case bson.MinKey:
+		return 0
+	case bson.MaxKey:
+		return 100
+	case bson.Undefined:
+		return 1
+	}
+	switch v.(type) {
+	case nil:
+		return 1
+	case int, int64, float64:
+		return 2
+	case string, bson.Symbol:
+		return 3
+	case bson.D, bson.M:
+		return 4
+	case []interface{}:
+		return 5
+	case bson.Binary, []byte:
+		return 6
+	case bson.ObjectId:
+		return 7
+	case bool:
+		return 8
+	case time.Time:
+		return 9
+	case bson.MongoTimestamp:
+		return 10
+	case bson.RegEx:
+		return 11
+	}
+	return 50
+}
+
+// compareNumbers compares integers exactly, hashed shard keys use the whole
+// int64 range a float64 cannot tell apart, and the rest as float64
+func compareNumbers(a interface{}, b interface{}) int {
+	ia, aInt := toInt64(a)
+	ib, bInt := toInt64(b)
+	if aInt && bInt {
+		switch {
+		case ia < ib:
+			return -1
+		case ia > ib:
+			return 1
+		}
+		return 0
+	}
+	return compareFloats(toFloat(a), toFloat(b))
+}
+
+// compareBinary orders BinData the way MongoDB does, by length first, then
+// subtype and then the bytes
+func compareBinary(a interface{}, b interface{}) int {
+	ka, da := toBinary(a)
+	kb, db := toBinary(b)
+	if len(da) != len(db) {
+		return len(da) - len(db)
+	}
+	if ka != kb {
+		return int(ka) - int(kb)
+	}
+	return bytes.Compare(da, db)
+}
+
+// toBinary returns the subtype and data of v, mgo decodes the generic
+// subtype 0 as a plain []byte
+func toBinary(v interface{}) (byte, []byte) {
+	switch bin := v.(type) {
+	case bson.Binary:
+		return bin.Kind, bin.Data
+	case []byte:
+		return 0, bin
+	}
+	return 0, nil
+}
+
+func toInt64(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), true
+	case int64:
+		return n, true
+	}
+	return 0, false
+}
+
+func toFloat(v interface{}) float64 {
+	switch n := v.(type) {
+	case int:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case float64:
+		return n
+	}
+	return 0
+}
+
+func toString(v interface{}) string {
+	if s, ok := v.(bson.Symbol); ok {
+		return string(s)
+	}
+	s, _ := v.(string)
+	return s
+}
+
+func compareFloats(a float64, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// collectionZones is the JSON record of the zones of a collection
+type collectionZones struct {
+	Collection string       `json:"collection"`
+	Zones      []ZoneChunks `json:"zones"`
+	Error      string       `json:"error,omitempty"`
+}
+
+// renderZones marks failed collections in the table and leaves them out of
+// the CSV records
+func renderZones(w io.Writer, format string, report Report) error {
+	v := view{header: zonesHeader, csvHeader: zonesHeader}
+	zoned := []collectionZones{}
+	for _, c := range report.Collections {
+		if len(c.Zones) > 0 || c.Error != "" {
+			zoned = append(zoned, collectionZones{c.Name, c.Zones, c.Error})
+			v.records = append(v.records, zoned[len(zoned)-1])
+		}
+		if c.Error != "" {
+			v.rows = append(v.rows, errorRow(c.Name, len(zonesHeader)))
+			continue
+		}
+		for _, z := range c.Zones {
+			v.rows = append(v.rows, z.row(c.Name))
+			v.csvRows = append(v.csvRows, z.row(c.Name))
+		}
+	}
+	v.document = struct {
+		Collections []collectionZones `json:"collections"`
+	}{zoned}
+	return renderView(w, format, v)
+}
+
+// row shows the chunks outside every zone range as "(none)"
+func (z ZoneChunks) row(collection string) []string {
+	zone := z.Zone
+	if zone == "" {
+		zone = "(none)"
+	}
+	return []string{
+		collection,
+		zone,
+		strconv.Itoa(z.Ranges),
+		strings.Join(z.Shards, ","),
+		strconv.Itoa(z.Chunks),
+		strconv.Itoa(z.IdealChunksPerShard),
+		strconv.Itoa(z.RemainChunks),
+		strconv.Itoa(z.Violations),
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// key is a single field shard key bound
+func key(v interface{}) bson.D {
+	return bson.D{{Name: "x", Value: v}}
+}
+
+func TestCompareBounds(t *testing.T) {
+	oid1 := bson.ObjectIdHex("5f0000000000000000000001")
+	oid2 := bson.ObjectIdHex("5f0000000000000000000002")
+	tests := []struct {
+		a    bson.D
+		b    bson.D
+		want int
+	}{
+		{key(bson.MinKey), key(bson.MinKey), 0},
+		{key(bson.MinKey), key(int64(-1 << 63)), -1},
+		{key(bson.MaxKey), key("zzz"), 1},
+		{key(bson.MaxKey), key(bson.MaxKey), 0},
+		{key(nil), key(0), -1},
+		{key(1), key(int64(1)), 0},
+		{key(1), key(1.5), -1},
+		{key(2), key(1.5), 1},
+		// hashed bounds a float64 rounds to the same value
+		{key(int64(4611686018427387903)), key(int64(4611686018427387902)), 1},
+		{key(int64(-4611686018427387903)), key(int64(-4611686018427387902)), -1},
+		{key(100), key("1"), -1},
+		{key("a"), key("b"), -1},
+		{key("a"), key(bson.Symbol("a")), 0},
+		{key(bson.Symbol("b")), key("a"), 1},
+		{key(oid1), key(oid2), -1},
+		// BinData compares by length, then subtype, then bytes
+		{key(uuid(0x02)), key(uuid(0x01)), 1},
+		{key([]byte{0xff}), key(uuid(0x00)), -1},
+		{key([]byte{0x01, 0x02}), key(bson.Binary{Kind: 0x80, Data: []byte{0x01, 0x01}}), -1},
+		{key([]byte{0x01}), key(bson.Binary{Kind: 0x00, Data: []byte{0x01}}), 0},
+		{key(false), key(true), -1},
+		// compound keys compare field by field
+		{
+			bson.D{{Name: "a", Value: "x"}, {Name: "b", Value: 5}},
+			bson.D{{Name: "a", Value: "x"}, {Name: "b", Value: 7}},
+			-1,
+		},
+		{
+			bson.D{{Name: "a", Value: "y"}, {Name: "b", Value: bson.MinKey}},
+			bson.D{{Name: "a", Value: "x"}, {Name: "b", Value: bson.MaxKey}},
+			1,
+		},
+		{
+			bson.D{{Name: "a", Value: "x"}, {Name: "b", Value: bson.MinKey}},
+			bson.D{{Name: "a", Value: "x"}, {Name: "b", Value: bson.MinKey}},
+			0,
+		},
+	}
+	for _, tt := range tests {
+		got := compareBounds(tt.a, tt.b)
+		if sign(got) != tt.want {
+			t.Errorf("compareBounds(%v, %v) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if sign(compareBounds(tt.b, tt.a)) != -tt.want {
+			t.Errorf("compareBounds(%v, %v) is not antisymmetric", tt.b, tt.a)
+		}
+	}
+}
+
+// uuid is a UUID shard key value ending with last
+func uuid(last byte) bson.Binary {
+	data := make([]byte, 16)
+	data[15] = last
+	return bson.Binary{Kind: 0x04, Data: data}
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
+
+func TestSummarizeZones(t *testing.T) {
+	ranges := []ZoneRange{
+		{Min: key(int64(100)), Max: key(bson.MaxKey), Tag: "EU"},
+		{Min: key(bson.MinKey), Max: key(int64(50)), Tag: "US"},
+	}
+	shards := ShardSlice{
+		{ID: "s1", Tags: []string{"US"}},
+		{ID: "s2", Tags: []string{"US"}},
+		{ID: "s3", Tags: []string{"EU"}},
+	}
+	chunks := ChunkSlice{
+		{Min: key(bson.MinKey), Shard: "s1"},
+		{Min: key(int64(10)), Shard: "s1"},
+		{Min: key(int64(20)), Shard: "s1"},
+		{Min: key(int64(30)), Shard: "s3"}, // US chunk on an EU shard
+		{Min: key(int64(50)), Shard: "s2"}, // outside every zone
+		{Min: key(int64(100)), Shard: "s3"},
+		{Min: key(200.5), Shard: "s1"}, // EU chunk on a US shard
+	}
+	want := []ZoneChunks{
+		{Zone: "US", Ranges: 1, Shards: []string{"s1", "s2"}, Chunks: 4, IdealChunksPerShard: 2, RemainChunks: 2, Violations: 1},
+		{Zone: "EU", Ranges: 1, Shards: []string{"s3"}, Chunks: 2, IdealChunksPerShard: 2, RemainChunks: 1, Violations: 1},
+		{Zone: "", Ranges: 0, Shards: []string{"s1", "s2", "s3"}, Chunks: 1, IdealChunksPerShard: 1, RemainChunks: 0, Violations: 0},
+	}
+	got := summarizeZones(chunks, ranges, shards)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("summarizeZones =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestSummarizeZonesHashed(t *testing.T) {
+	// adjacent hashed bounds a float64 comparison would merge
+	split := int64(4611686018427387903)
+	ranges := []ZoneRange{
+		{Min: key(bson.MinKey), Max: key(split), Tag: "A"},
+		{Min: key(split), Max: key(bson.MaxKey), Tag: "B"},
+	}
+	shards := ShardSlice{{ID: "a", Tags: []string{"A"}}, {ID: "b", Tags: []string{"B"}}}
+	chunks := ChunkSlice{
+		{Min: key(split - 1), Shard: "a"},
+		{Min: key(split), Shard: "b"},
+	}
+	for _, z := range summarizeZones(chunks, ranges, shards) {
+		if z.Chunks != 1 || z.Violations != 0 {
+			t.Errorf("zone %s has %d chunks and %d violations, want 1 and 0", z.Zone, z.Chunks, z.Violations)
+		}
+	}
+}
+
+func TestSummarizeZonesBinary(t *testing.T) {
+	ranges := []ZoneRange{
+		{Min: key(uuid(0x80)), Max: key(bson.MaxKey), Tag: "B"},
+		{Min: key(bson.MinKey), Max: key(uuid(0x80)), Tag: "A"},
+	}
+	shards := ShardSlice{{ID: "a", Tags: []string{"A"}}, {ID: "b", Tags: []string{"B"}}}
+	chunks := ChunkSlice{
+		{Min: key(bson.MinKey), Shard: "a"},
+		{Min: key(uuid(0x40)), Shard: "a"},
+		{Min: key(uuid(0x80)), Shard: "b"},
+	}
+	want := []ZoneChunks{
+		{Zone: "A", Ranges: 1, Shards: []string{"a"}, Chunks: 2, IdealChunksPerShard: 2, RemainChunks: 0, Violations: 0},
+		{Zone: "B", Ranges: 1, Shards: []string{"b"}, Chunks: 1, IdealChunksPerShard: 1, RemainChunks: 0, Violations: 0},
+	}
+	got := summarizeZones(chunks, ranges, shards)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("summarizeZones =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestZoneDataToMigrate(t *testing.T) {
+	ranges := []ZoneRange{
+		{Min: key(bson.MinKey), Max: key(int64(100)), Tag: "US"},
+	}
+	shards := ShardSlice{
+		{ID: "s1", Tags: []string{"US"}},
+		{ID: "s2", Tags: []string{"US"}},
+		{ID: "s3", Tags: []string{"EU"}},
+	}
+	chunks := ChunkSlice{
+		{Min: key(bson.MinKey), Shard: "s1"},
+		{Min: key(int64(10)), Shard: "s1"},
+		{Min: key(int64(20)), Shard: "s3"}, // US chunk on an EU shard
+		{Min: key(int64(100)), Shard: "s3"},
+		{Min: key(int64(200)), Shard: "s3"},
+	}
+	sizes := []int64{300, 100, 50, 60, 60}
+	// US holds 400 on s1 and none on s2, 200 above the ideal, the chunk on
+	// s3 moves entirely and s3 holds 80 of the chunks outside the zone
+	// above the ideal of 40 over all three shards
+	if got := zoneDataToMigrate(chunks, sizes, ranges, shards); got != 330 {
+		t.Errorf("zoneDataToMigrate = %d, want 330", got)
+	}
+}